@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+)
+
+type adjustment struct {
+	Device string `json:"device"`
+	Old    int    `json:"old"`
+	New    int    `json:"new"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// doAdjust sets value on every device behind target after reading each
+// device's current value, and prints the outcome.
+func doAdjust(ctx context.Context, action string, r valueRange, value, target string, force bool, tr transition) error {
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	results, err := adjustTarget(ctx, c, action, r, value, target, force, tr)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, a := range results {
+		if a.Error != "" {
+			failed++
+		}
+		if a.Status == "interrupted" {
+			fmt.Fprintln(os.Stderr, "Interrupted: devices left at their last values")
+			break
+		}
+	}
+
+	tbl := display.NewTable("Device", "Old", "New", "Status")
+	for _, a := range results {
+		status := a.Status
+		if a.Error != "" {
+			status = "error: " + a.Error
+		}
+		tbl.AddRow(a.Device, strconv.Itoa(a.Old), strconv.Itoa(a.New), status)
+	}
+	out := display.Output{Data: results, Table: tbl}
+	if len(results) == 1 && failed == 0 {
+		out.Text = results[0].Status + "\n"
+	}
+	err = render(out)
+	if failed > 0 {
+		return fmt.Errorf("failed to adjust %d of %d devices", failed, len(results))
+	}
+	return err
+}
+
+// adjustTarget reads the current value of every device behind target, so a
+// relative value keeps the differences between the members of a room or
+// group, then applies it. With tr.over set the change is spread out over
+// time by runTransition. Per-device failures are reported in the results.
+func adjustTarget(ctx context.Context, c *client.Client, action string, r valueRange, value, target string, force bool, tr transition) ([]adjustment, error) {
+	infos, err := c.GetInfoContext(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []adjustment
+	for _, info := range infos {
+		cur, ok := info.State[r.stateKey]
+		if !ok {
+			continue
+		}
+		old := toFloat(cur)
+		adj := adjustment{Device: deviceKey(info.Room, info.Name), Old: int(math.Round(old))}
+		if isRelative(value) {
+			adj.New = r.apply(old, value, force)
+		} else {
+			adj.New, _ = strconv.Atoi(value)
+		}
+		results = append(results, adj)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no device in %s reports %s", target, r.stateKey)
+	}
+
+	if tr.over > 0 {
+		runTransition(ctx, c, action, results, tr)
+	} else {
+		for i := range results {
+			sendAdjustment(ctx, c, action, &results[i], results[i].New)
+		}
+	}
+	return results, nil
+}
+
+func sendAdjustment(ctx context.Context, c *client.Client, action string, adj *adjustment, value int) bool {
+	resp, err := c.ControlValue(ctx, action, strconv.Itoa(value), adj.Device)
+	if err != nil {
+		adj.Status = "error"
+		adj.Error = err.Error()
+		return false
+	}
+	adj.Status = resp.Status
+	adj.Error = ""
+	return true
+}
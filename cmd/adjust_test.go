@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+)
+
+func adjustHandler(t *testing.T, infos []client.DeviceInfo, sent map[string]bool, fail string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/info/") {
+			json.NewEncoder(w).Encode(infos)
+			return
+		}
+		sent[r.URL.Path] = true
+		if fail != "" && strings.HasSuffix(r.URL.Path, fail) {
+			json.NewEncoder(w).Encode(map[string]string{"status": "error", "message": "device not responding"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	}
+}
+
+func TestAdjustSingleDevice(t *testing.T) {
+	sent := map[string]bool{}
+	setupTestEnv(t, adjustHandler(t, []client.DeviceInfo{
+		{Name: "Lamp", Room: "Office", Reachable: true, State: map[string]interface{}{"brightness": float64(40)}},
+	}, sent, ""))
+
+	outputFormat = display.FormatTable
+	out := captureStdout(t, func() {
+		if _, err := executeCmd("brightness", "+10", "Office/Lamp"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	if !sent["/brightness/50/Office/Lamp"] {
+		t.Errorf("expected /brightness/50/Office/Lamp, got %v", sent)
+	}
+	if strings.TrimSpace(out) != "success" {
+		t.Errorf("unexpected output: %q", out)
+	}
+}
+
+func TestAdjustNegativeClampsEachDevice(t *testing.T) {
+	sent := map[string]bool{}
+	setupTestEnv(t, adjustHandler(t, []client.DeviceInfo{
+		{Name: "Lamp", Room: "Office", State: map[string]interface{}{"colorTemperature": float64(300)}},
+		{Name: "Strip", Room: "Office", State: map[string]interface{}{"colorTemperature": float64(170)}},
+		{Name: "Fan", Room: "Office", State: map[string]interface{}{"speed": float64(50)}},
+	}, sent, ""))
+
+	outputFormat = display.FormatTable
+	out := captureStdout(t, func() {
+		if _, err := executeCmd("temp", "-50", "Office"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	if !sent["/temp/250/Office/Lamp"] || !sent["/temp/140/Office/Strip"] || len(sent) != 2 {
+		t.Errorf("unexpected requests: %v", sent)
+	}
+	if !strings.Contains(out, "Office/Strip") || !strings.Contains(out, "140") {
+		t.Errorf("expected table output, got %q", out)
+	}
+}
+
+func TestAdjustJSONAndFailure(t *testing.T) {
+	sent := map[string]bool{}
+	setupTestEnv(t, adjustHandler(t, []client.DeviceInfo{
+		{Name: "Left", State: map[string]interface{}{"position": float64(10)}},
+		{Name: "Right", State: map[string]interface{}{"position": float64(20)}},
+	}, sent, "/Right"))
+
+	var err error
+	out := captureStdout(t, func() {
+		_, err = executeCmd("position", "--json", "+25%", "group.Blinds")
+	})
+	if err == nil || err.Error() != "failed to adjust 1 of 2 devices" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var results []adjustment
+	if jerr := json.Unmarshal([]byte(out), &results); jerr != nil {
+		t.Fatalf("invalid JSON %q: %v", out, jerr)
+	}
+	if len(results) != 2 || results[0].New != 35 || results[1].Error != "device not responding" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+}
+
+func TestAdjustTableShowsErrors(t *testing.T) {
+	sent := map[string]bool{}
+	setupTestEnv(t, adjustHandler(t, []client.DeviceInfo{
+		{Name: "Lamp", State: map[string]interface{}{"brightness": float64(40)}},
+	}, sent, "/Lamp"))
+
+	outputFormat = display.FormatTable
+	var err error
+	out := captureStdout(t, func() {
+		_, err = executeCmd("brightness", "+10", "Lamp")
+	})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(out, "error: device not responding") {
+		t.Errorf("expected error in table, got %q", out)
+	}
+}
+
+func TestAdjustNoMatchingProperty(t *testing.T) {
+	setupTestEnv(t, adjustHandler(t, []client.DeviceInfo{
+		{Name: "Fan", State: map[string]interface{}{"speed": float64(50)}},
+	}, map[string]bool{}, ""))
+
+	_, err := executeCmd("brightness", "+10", "Fan")
+	if err == nil || err.Error() != "no device in Fan reports brightness" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAdjustInfoError(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"status": "error", "message": "not found"})
+	})
+
+	if _, err := executeCmd("brightness", "+10", "Nope"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestAdjustConfigError(t *testing.T) {
+	setupTestEnv(t, nil)
+	t.Setenv("ITSYHOME_PORT", "bogus")
+
+	if _, err := executeCmd("brightness", "+10", "Lamp"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the local cache of rooms, devices, scenes and groups",
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all cached lists",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := listCache()
+		if err != nil {
+			return err
+		}
+		if err := store.Clear(); err != nil {
+			return err
+		}
+		fmt.Printf("Cleared %s\n", store.Dir())
+		return nil
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+)
+
+// cacheServer serves one room and counts requests.
+func cacheServer(t *testing.T) *int32 {
+	var requests int32
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		json.NewEncoder(w).Encode([]client.Room{{Name: "Office"}})
+	})
+	return &requests
+}
+
+func TestListCacheFlags(t *testing.T) {
+	requests := cacheServer(t)
+
+	outputFormat = display.FormatTable
+	tests := []struct {
+		args []string
+		want int32
+	}{
+		{[]string{"list", "rooms"}, 1},
+		{[]string{"list", "rooms"}, 1},
+		{[]string{"list", "rooms", "--refresh"}, 2},
+		{[]string{"list", "rooms", "--no-cache"}, 3},
+		{[]string{"list", "rooms", "--cache-ttl", "0"}, 4},
+		{[]string{"list", "rooms", "--offline"}, 4},
+	}
+	captureStdout(t, func() {
+		for _, tt := range tests {
+			if _, err := executeCmd(tt.args...); err != nil {
+				t.Fatalf("%v: unexpected error: %v", tt.args, err)
+			}
+			if got := atomic.LoadInt32(requests); got != tt.want {
+				t.Errorf("%v: expected %d requests in total, got %d", tt.args, tt.want, got)
+			}
+		}
+	})
+}
+
+func TestOfflineErrors(t *testing.T) {
+	requests := cacheServer(t)
+
+	tests := []struct {
+		args   []string
+		errMsg string
+	}{
+		{[]string{"list", "scenes", "--offline"}, "offline: /list/scenes has not been cached yet"},
+		{[]string{"info", "Office", "--offline"}, "offline: /info/Office needs the server"},
+		{[]string{"list", "rooms", "--offline", "--refresh"}, "--offline cannot be used with --no-cache or --refresh"},
+		{[]string{"list", "rooms", "--offline", "--no-cache"}, "--offline cannot be used with --no-cache or --refresh"},
+	}
+	for _, tt := range tests {
+		if _, err := executeCmd(tt.args...); err == nil || err.Error() != tt.errMsg {
+			t.Errorf("%v: expected %q, got %v", tt.args, tt.errMsg, err)
+		}
+	}
+	if got := atomic.LoadInt32(requests); got != 0 {
+		t.Errorf("expected no requests, got %d", got)
+	}
+}
+
+func TestCacheClear(t *testing.T) {
+	requests := cacheServer(t)
+
+	outputFormat = display.FormatTable
+	out := captureStdout(t, func() {
+		executeCmd("list", "rooms")
+		if _, err := executeCmd("cache", "clear"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		executeCmd("list", "rooms")
+	})
+	dir := filepath.Join(os.Getenv("HOME"), ".cache", "itsyhome")
+	if !strings.Contains(out, "Cleared "+dir+"\n") {
+		t.Errorf("unexpected output: %q", out)
+	}
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("expected the list to be fetched again, got %d requests", got)
+	}
+}
+
+func TestCacheDirErrors(t *testing.T) {
+	requests := cacheServer(t)
+	original := userCacheDir
+	defer func() { userCacheDir = original }()
+
+	userCacheDir = func() (string, error) { return "", errors.New("no cache dir") }
+	if _, err := executeCmd("cache", "clear"); err == nil || err.Error() != "no cache dir" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	outputFormat = display.FormatTable
+	captureStdout(t, func() {
+		executeCmd("list", "rooms")
+		executeCmd("list", "rooms")
+	})
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("expected lists to be fetched without a cache, got %d requests", got)
+	}
+
+	// Clear fails when the cache directory cannot be removed
+	userCacheDir = func() (string, error) { return "bad\x00dir", nil }
+	if _, err := executeCmd("cache", "clear"); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestListCacheFallback(t *testing.T) {
+	var down, devices int32
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&down) == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, "/list/devices") {
+			atomic.AddInt32(&devices, 1)
+			json.NewEncoder(w).Encode([]client.Device{{Name: "Lamp", Room: "Office", Reachable: true}})
+			return
+		}
+		json.NewEncoder(w).Encode([]client.Room{{Name: "Office"}})
+	})
+
+	outputFormat = display.FormatTable
+	var out string
+	errOut := captureStderr(t, func() {
+		out = captureStdout(t, func() {
+			for _, args := range [][]string{{"list", "rooms"}, {"list", "devices"}, {"list", "devices"}} {
+				if _, err := executeCmd(args...); err != nil {
+					t.Fatalf("%v: unexpected error: %v", args, err)
+				}
+			}
+			if got := atomic.LoadInt32(&devices); got != 2 {
+				t.Errorf("expected device lists to skip the cache, got %d requests", got)
+			}
+
+			atomic.StoreInt32(&down, 1)
+			for _, args := range [][]string{{"list", "rooms", "--refresh"}, {"list", "devices"}} {
+				if _, err := executeCmd(args...); err != nil {
+					t.Fatalf("%v: unexpected error: %v", args, err)
+				}
+			}
+			if _, err := executeCmd("list", "scenes"); err == nil || !strings.HasPrefix(err.Error(), "connection failed") {
+				t.Errorf("expected a connection error for an uncached list, got %v", err)
+			}
+		})
+	})
+	if strings.Count(out, "Office") != 5 {
+		t.Errorf("expected cached rooms and devices, got %q", out)
+	}
+	for _, path := range []string{"/list/rooms", "/list/devices"} {
+		if !strings.Contains(errOut, "Warning: server unreachable, using "+path+" cached 0s ago") {
+			t.Errorf("expected a stale notice for %s, got %q", path, errOut)
+		}
+	}
+}
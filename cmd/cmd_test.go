@@ -2,17 +2,43 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/config"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
+func TestMain(m *testing.M) {
+	// Tests point HOME at a temp dir; a developer's XDG_CONFIG_HOME or
+	// XDG_CACHE_HOME would otherwise take precedence.
+	os.Unsetenv("XDG_CONFIG_HOME")
+	os.Unsetenv("XDG_CACHE_HOME")
+	// Connection settings from the environment or the machine-wide config
+	// file would send test requests to a real server.
+	os.Setenv(config.SystemConfigEnv, filepath.Join(os.TempDir(), "itsyhome-test-no-system-config.json"))
+	for _, env := range []string{
+		config.ProfileEnv, config.HostEnv, config.PortEnv, config.URLEnv,
+		config.TokenEnv, config.TokenFileEnv, config.SchemeEnv, config.CACertEnv,
+		config.ClientCertEnv, config.ClientKeyEnv, config.InsecureSkipVerifyEnv,
+	} {
+		os.Unsetenv(env)
+	}
+	os.Exit(m.Run())
+}
+
 // setupTestEnv sets up a test server and config pointing to it.
 // The config.json will have host and port matching the test server.
 func setupTestEnv(t *testing.T, handler http.HandlerFunc) {
@@ -50,12 +76,78 @@ func setupTestEnv(t *testing.T, handler http.HandlerFunc) {
 	os.WriteFile(filepath.Join(dir, "config.json"), cfgData, 0644)
 }
 
+// requestLog records the paths of requests a test server received. The
+// handler runs on the server's goroutines, so access is locked.
+type requestLog struct {
+	mu   sync.Mutex
+	seen []string
+}
+
+func (l *requestLog) record(r *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seen = append(l.seen, r.URL.Path)
+}
+
+// paths returns a copy of the recorded paths in the order they arrived.
+func (l *requestLog) paths() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.seen...)
+}
+
+func testClient(t *testing.T) *client.Client {
+	t.Helper()
+	c, err := newClient()
+	if err != nil {
+		t.Fatalf("newClient: %v", err)
+	}
+	return c
+}
+
+// resetFlags restores every flag to its default so values set by one test
+// do not leak into the next.
+func resetFlags(c *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			sv.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	c.Flags().VisitAll(reset)
+	c.PersistentFlags().VisitAll(reset)
+	for _, sub := range c.Commands() {
+		resetFlags(sub)
+	}
+}
+
 func executeCmd(args ...string) (string, error) {
+	return executeCmdContext(context.Background(), args...)
+}
+
+// setContext gives every command ctx. Cobra only copies the root context to
+// a subcommand that has none yet, so without this the first test's context
+// would stick to each command.
+func setContext(c *cobra.Command, ctx context.Context) {
+	c.SetContext(ctx)
+	for _, sub := range c.Commands() {
+		setContext(sub, ctx)
+	}
+}
+
+func executeCmdContext(ctx context.Context, args ...string) (string, error) {
+	resetFlags(rootCmd)
+	setContext(rootCmd, ctx)
 	buf := new(bytes.Buffer)
 	rootCmd.SetOut(buf)
 	rootCmd.SetErr(buf)
 	rootCmd.SetArgs(args)
-	err := rootCmd.Execute()
+	err := rootCmd.ExecuteContext(ctx)
 	return buf.String(), err
 }
 
@@ -88,7 +180,7 @@ func TestStatusCmd(t *testing.T) {
 		}
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("status")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -110,7 +202,7 @@ func TestStatusCmdEmptyRoom(t *testing.T) {
 		}
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("status")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -135,7 +227,7 @@ func TestStatusCmdAllUnreachable(t *testing.T) {
 		}
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("status")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -155,7 +247,7 @@ func TestStatusCmdListRoomsError(t *testing.T) {
 		}
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("status")
 	if err == nil {
 		t.Fatal("expected error")
@@ -177,13 +269,72 @@ func TestStatusCmdGetInfoError(t *testing.T) {
 		}
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("status")
 	if err == nil {
 		t.Fatal("expected error")
 	}
 }
 
+func TestStatusCmdPartialFailure(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/status":
+			json.NewEncoder(w).Encode(map[string]int{"rooms": 2, "devices": 1})
+		case "/list/rooms":
+			json.NewEncoder(w).Encode([]map[string]string{{"name": "Office"}, {"name": "Garage"}})
+		case "/info/Office":
+			json.NewEncoder(w).Encode([]client.DeviceInfo{
+				{Name: "Lamp", Type: "light", Reachable: true, State: map[string]interface{}{"on": true}},
+			})
+		default:
+			w.WriteHeader(500)
+		}
+	})
+
+	for _, format := range []display.Format{display.FormatTable, display.FormatJSON} {
+		outputFormat = format
+		_, err := executeCmd("status")
+		if err == nil || err.Error() != "failed to fetch 1 of 2 rooms" {
+			t.Errorf("%s: expected partial failure error, got %v", format, err)
+		}
+	}
+	outputFormat = display.FormatTable
+}
+
+func TestFetchRoomInfosPreservesOrder(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path[len("/info/"):]
+		if name == "A" {
+			time.Sleep(30 * time.Millisecond)
+		}
+		if name == "C" {
+			w.WriteHeader(500)
+			return
+		}
+		json.NewEncoder(w).Encode([]client.DeviceInfo{{Name: name + " Lamp"}})
+	})
+
+	rooms := []client.Room{{Name: "A"}, {Name: "B"}, {Name: "C"}, {Name: "D"}}
+	for _, n := range []int{0, 1, 4} {
+		results := fetchRoomInfos(context.Background(), testClient(t), rooms, n)
+		if len(results) != 4 {
+			t.Fatalf("expected 4 results, got %d", len(results))
+		}
+		for i, room := range rooms {
+			if room.Name == "C" {
+				if results[i].err == nil {
+					t.Errorf("concurrency %d: expected error for room C", n)
+				}
+				continue
+			}
+			if results[i].err != nil || results[i].infos[0].Name != room.Name+" Lamp" {
+				t.Errorf("concurrency %d: unexpected result for %s: %+v", n, room.Name, results[i])
+			}
+		}
+	}
+}
+
 func TestStatusCmdJSON(t *testing.T) {
 	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
@@ -202,7 +353,7 @@ func TestStatusCmdJSON(t *testing.T) {
 		}
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("status", "--json")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -216,7 +367,7 @@ func TestStatusCmdWithRoom(t *testing.T) {
 		})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("status", "Office")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -230,7 +381,7 @@ func TestStatusCmdWithRoomJSON(t *testing.T) {
 		})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("status", "--json", "Office")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -242,7 +393,7 @@ func TestStatusCmdError(t *testing.T) {
 		w.WriteHeader(500)
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("status")
 	if err == nil {
 		t.Fatal("expected error")
@@ -254,7 +405,7 @@ func TestStatusCmdRoomError(t *testing.T) {
 		w.WriteHeader(500)
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("status", "Office")
 	if err == nil {
 		t.Fatal("expected error")
@@ -367,6 +518,17 @@ func TestFormatValueMultiple(t *testing.T) {
 	}
 }
 
+func TestFormatValueColorTemperature(t *testing.T) {
+	info := client.DeviceInfo{State: map[string]interface{}{
+		"brightness":       float64(60),
+		"colorTemperature": float64(370),
+	}}
+	result := formatValue(info)
+	if result != "60%, 2703K" {
+		t.Errorf("expected '60%%, 2703K', got %s", result)
+	}
+}
+
 // --- toFloat tests ---
 
 func TestToFloatFloat64(t *testing.T) {
@@ -401,7 +563,7 @@ func TestToggleCmd(t *testing.T) {
 		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("toggle", "Office", "Lamp")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -413,7 +575,7 @@ func TestToggleCmdJSON(t *testing.T) {
 		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("toggle", "--json", "Lamp")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -425,7 +587,7 @@ func TestToggleCmdError(t *testing.T) {
 		json.NewEncoder(w).Encode(map[string]string{"status": "error", "message": "not found"})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("toggle", "Unknown")
 	if err == nil {
 		t.Fatal("expected error")
@@ -437,7 +599,7 @@ func TestOnCmd(t *testing.T) {
 		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("on", "Lamp")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -449,7 +611,7 @@ func TestOffCmd(t *testing.T) {
 		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("off", "Lamp")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -461,7 +623,7 @@ func TestBrightnessCmd(t *testing.T) {
 		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("brightness", "80", "Lamp")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -476,19 +638,37 @@ func TestSpeedCmd(t *testing.T) {
 		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("speed", "80", "Bedroom", "Fan")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
 
+func TestControlCmdEscapesTarget(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/on/Kids%20%231/Lamp%3F" {
+			t.Errorf("unexpected escaped path: %s", r.URL.EscapedPath())
+		}
+		if r.URL.Path != "/on/Kids #1/Lamp?" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	})
+
+	outputFormat = display.FormatTable
+	_, err := executeCmd("on", "Kids", "#1/Lamp?")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
 func TestSceneCmd(t *testing.T) {
 	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("scene", "Goodnight")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -505,13 +685,70 @@ func TestInfoCmdSingle(t *testing.T) {
 		})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("info", "Lamp")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
 
+func TestInfoCmdSingleColorTemperature(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(client.DeviceInfo{
+			Name: "Lamp", Type: "light", Reachable: true,
+			State: map[string]interface{}{"colorTemperature": float64(250)},
+		})
+	})
+
+	outputFormat = display.FormatTable
+	out := captureStdout(t, func() {
+		if _, err := executeCmd("info", "Lamp"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	if !strings.Contains(out, "250 (4000K)") {
+		t.Errorf("expected Kelvin equivalent, got %q", out)
+	}
+}
+
+func TestInfoCmdSingleColorSwatch(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]client.DeviceInfo{
+			{Name: "Strip", Type: "light", Reachable: true, State: map[string]interface{}{"hue": float64(24), "saturation": float64(100)}},
+		})
+	})
+
+	outputFormat = display.FormatTable
+	out := captureStdout(t, func() {
+		if _, err := executeCmd("info", "Strip"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	if strings.Contains(out, "\033") || !strings.Contains(out, "color      | #FF6600") {
+		t.Errorf("expected plain hex outside a terminal, got %q", out)
+	}
+
+	fakeTerminal(t)
+	out = captureStdout(t, func() {
+		if _, err := executeCmd("info", "Strip"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	if !strings.Contains(out, "\033[48;2;255;102;0m  \033[0m #FF6600") {
+		t.Errorf("expected swatch and hex, got %q", out)
+	}
+}
+
+func TestStateColorDefaultsSaturation(t *testing.T) {
+	c, ok := stateColor(client.DeviceInfo{State: map[string]interface{}{"hue": float64(240)}})
+	if !ok || c.Hex() != "0000FF" {
+		t.Errorf("expected 0000FF, got %s (%v)", c.Hex(), ok)
+	}
+	if _, ok := stateColor(client.DeviceInfo{State: map[string]interface{}{"brightness": float64(50)}}); ok {
+		t.Error("expected no color without hue")
+	}
+}
+
 func TestInfoCmdSingleUnreachable(t *testing.T) {
 	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(client.DeviceInfo{
@@ -519,7 +756,7 @@ func TestInfoCmdSingleUnreachable(t *testing.T) {
 		})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("info", "Lamp")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -534,11 +771,20 @@ func TestInfoCmdMultiple(t *testing.T) {
 		})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("info", "Office")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+
+	out := captureStdout(t, func() {
+		if _, err := executeCmd("info", "Office", "-o", "jsonpath={[1].name}"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	if out != "Lamp 2" {
+		t.Errorf("expected several devices to render as a list, got %q", out)
+	}
 }
 
 func TestInfoCmdJSON(t *testing.T) {
@@ -548,19 +794,35 @@ func TestInfoCmdJSON(t *testing.T) {
 		})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("info", "--json", "Lamp")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
 
+func TestInfoCmdRoomWithOneDeviceJSON(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]client.DeviceInfo{{Name: "Light", Room: "Porch", Reachable: true}})
+	})
+
+	out := captureStdout(t, func() {
+		if _, err := executeCmd("info", "Porch", "--json"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	var infos []client.DeviceInfo
+	if err := json.Unmarshal([]byte(out), &infos); err != nil || len(infos) != 1 || infos[0].Name != "Light" {
+		t.Errorf("expected a one-element array, got %q (%v)", out, err)
+	}
+}
+
 func TestInfoCmdError(t *testing.T) {
 	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(500)
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("info", "Unknown")
 	if err == nil {
 		t.Fatal("expected error")
@@ -574,7 +836,7 @@ func TestListRoomsCmd(t *testing.T) {
 		json.NewEncoder(w).Encode([]map[string]string{{"name": "Office"}, {"name": "Bedroom"}})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("list", "rooms")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -586,19 +848,33 @@ func TestListRoomsCmdJSON(t *testing.T) {
 		json.NewEncoder(w).Encode([]map[string]string{{"name": "Office"}})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("list", "--json", "rooms")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
 
+func TestListRoomsCmdTimeout(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		json.NewEncoder(w).Encode([]map[string]string{{"name": "Office"}})
+	})
+	defer func() { timeout = client.DefaultTimeout }()
+
+	outputFormat = display.FormatTable
+	_, err := executeCmd("list", "rooms", "--timeout", "20ms")
+	if err == nil {
+		t.Fatal("expected timeout error")
+	}
+}
+
 func TestListRoomsCmdError(t *testing.T) {
 	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(500)
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("list", "rooms")
 	if err == nil {
 		t.Fatal("expected error")
@@ -613,7 +889,7 @@ func TestListDevicesCmd(t *testing.T) {
 		})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("list", "devices")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -627,7 +903,7 @@ func TestListDevicesCmdWithRoom(t *testing.T) {
 		})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("list", "devices", "Office")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -641,7 +917,7 @@ func TestListDevicesCmdJSON(t *testing.T) {
 		})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("list", "--json", "devices")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -653,7 +929,7 @@ func TestListDevicesCmdError(t *testing.T) {
 		w.WriteHeader(500)
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("list", "devices")
 	if err == nil {
 		t.Fatal("expected error")
@@ -665,7 +941,7 @@ func TestListScenesCmd(t *testing.T) {
 		json.NewEncoder(w).Encode([]map[string]string{{"name": "Goodnight"}})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("list", "scenes")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -677,7 +953,7 @@ func TestListScenesCmdJSON(t *testing.T) {
 		json.NewEncoder(w).Encode([]map[string]string{{"name": "Goodnight"}})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("list", "--json", "scenes")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -689,7 +965,7 @@ func TestListScenesCmdError(t *testing.T) {
 		w.WriteHeader(500)
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("list", "scenes")
 	if err == nil {
 		t.Fatal("expected error")
@@ -703,7 +979,7 @@ func TestListGroupsCmd(t *testing.T) {
 		})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("list", "groups")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -717,7 +993,7 @@ func TestListGroupsCmdJSON(t *testing.T) {
 		})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("list", "--json", "groups")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -732,7 +1008,7 @@ func TestListGroupsCmdWithRoom(t *testing.T) {
 		})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("list", "groups")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -746,7 +1022,7 @@ func TestListGroupsCmdError(t *testing.T) {
 		w.WriteHeader(500)
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("list", "groups")
 	if err == nil {
 		t.Fatal("expected error")
@@ -759,7 +1035,7 @@ func TestConfigCmd(t *testing.T) {
 	tmp := t.TempDir()
 	t.Setenv("HOME", tmp)
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("config")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -770,7 +1046,7 @@ func TestConfigSetCmd(t *testing.T) {
 	tmp := t.TempDir()
 	t.Setenv("HOME", tmp)
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("config", "set", "--host", "10.0.0.1", "--port", "9999")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -792,18 +1068,377 @@ func TestConfigSetCmdSaveError(t *testing.T) {
 	os.MkdirAll(filepath.Dir(configDir), 0755)
 	os.WriteFile(configDir, []byte("not a dir"), 0644)
 
-	jsonOutput = false
-	// This should print an error but not return one (Run not RunE)
-	_, err := executeCmd("config", "set", "--host", "10.0.0.1")
+	outputFormat = display.FormatTable
+	if _, err := executeCmd("config", "set", "--host", "10.0.0.1"); err == nil {
+		t.Fatal("expected save error")
+	}
+}
+
+func TestConfigCmdUnknownProfile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(config.ProfileEnv, "missing")
+
+	if _, err := executeCmd("config"); err == nil {
+		t.Fatal("expected error for unknown profile")
+	}
+	if _, err := executeCmd("config", "set", "--host", "10.0.0.1"); err == nil || err.Error() != `unknown profile "missing"` {
+		t.Fatalf("expected unknown profile error, got %v", err)
+	}
+}
+
+func TestConfigProfileLifecycle(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(config.ProfileEnv, "")
+	defer func() { profileName = "" }()
+
+	steps := [][]string{
+		{"config", "profile", "add", "office", "--host", "10.0.0.2", "--port", "9000"},
+		{"config", "profile", "use", "office"},
+		{"config", "set", "--host", "10.0.0.3"},
+		{"config", "profile", "list"},
+		{"config", "profile", "list", "--json"},
+	}
+	for _, args := range steps {
+		if _, err := executeCmd(args...); err != nil {
+			t.Fatalf("%v: unexpected error: %v", args, err)
+		}
+	}
+	outputFormat = display.FormatTable
+
+	f := config.LoadFile()
+	if f.ActiveProfile != "office" || f.Profiles["office"].Host != "10.0.0.3" || f.Profiles["office"].Port != 9000 {
+		t.Fatalf("unexpected config file: %+v", f)
+	}
+	if f.Host != "" {
+		t.Errorf("default profile should be untouched, got host %q", f.Host)
+	}
+
+	if _, err := executeCmd("config", "profile", "remove", "office"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	f = config.LoadFile()
+	if f.ActiveProfile != "" || len(f.Profiles) != 0 {
+		t.Errorf("expected office removed and deactivated, got %+v", f)
+	}
+
+	if _, err := executeCmd("config", "profile", "use", "default"); err != nil {
+		t.Fatalf("use default: %v", err)
+	}
+}
+
+func TestConfigProfileErrors(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	t.Setenv(config.ProfileEnv, "")
+	config.SaveProfile("office", config.DefaultConfig())
+
+	cases := [][]string{
+		{"config", "profile", "add", "default"},
+		{"config", "profile", "add", "office"},
+		{"config", "profile", "use", "nope"},
+		{"config", "profile", "remove", "default"},
+		{"config", "profile", "remove", "nope"},
+	}
+	for _, args := range cases {
+		if _, err := executeCmd(args...); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+
+	original := saveConfigFile
+	saveConfigFile = func(config.File) error { return fmt.Errorf("disk full") }
+	defer func() { saveConfigFile = original }()
+
+	cases = [][]string{
+		{"config", "profile", "add", "cabin"},
+		{"config", "profile", "use", "default"},
+		{"config", "profile", "remove", "office"},
+	}
+	for _, args := range cases {
+		if _, err := executeCmd(args...); err == nil || err.Error() != "disk full" {
+			t.Errorf("%v: expected save error, got %v", args, err)
+		}
+	}
+}
+
+func TestConfigSetToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			w.WriteHeader(401)
+			return
+		}
+		json.NewEncoder(w).Encode([]map[string]string{{"name": "Office"}})
+	}))
+	defer srv.Close()
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(config.TokenEnv, "")
+	t.Setenv(config.TokenFileEnv, "")
+
+	if _, err := executeCmd("list", "rooms", "--url", srv.URL); err == nil {
+		t.Fatal("expected 401 without token")
+	}
+	if _, err := executeCmd("config", "set", "--token", "s3cret"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := executeCmd("list", "rooms", "--url", srv.URL); err != nil {
+		t.Fatalf("unexpected error with token: %v", err)
+	}
+	if _, err := executeCmd("config"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(config.Path()); strings.Contains(string(data), "s3cret") {
+		t.Error("token must not be written to config.json")
+	}
+
+	// An insecure token file is rejected
+	path := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(path, []byte("s3cret"), 0644)
+	if _, err := executeCmd("config", "set", "--token-file", path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := executeCmd("list", "rooms", "--url", srv.URL); err == nil {
+		t.Fatal("expected error for world-readable token file")
+	}
+	if _, err := executeCmd("config"); err == nil {
+		t.Fatal("expected error for world-readable token file")
+	}
+}
+
+func TestConnectionTLSFlags(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]string{{"name": "Office"}})
+	}))
+	defer srv.Close()
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(config.ProfileEnv, "")
+
+	outputFormat = display.FormatTable
+	if _, err := executeCmd("list", "rooms", "--url", srv.URL); err == nil {
+		t.Fatal("expected certificate error")
+	}
+	if _, err := executeCmd("list", "rooms", "--url", srv.URL, "--insecure-skip-verify"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := executeCmd("list", "rooms", "--url", srv.URL, "--ca-cert", "/nonexistent.pem"); err == nil {
+		t.Fatal("expected CA bundle error")
+	}
+
+	host, port, _ := strings.Cut(strings.TrimPrefix(srv.URL, "https://"), ":")
+	steps := [][]string{
+		{"config", "set", "--scheme", "https", "--host", host, "--port", port, "--insecure-skip-verify"},
+		{"list", "rooms"},
+		{"config", "--ca-cert", "/etc/ssl/lab.pem", "--client-cert", "/certs/cli.pem", "--client-key", "/certs/key.pem"},
+	}
+	for _, args := range steps {
+		if _, err := executeCmd(args...); err != nil {
+			t.Fatalf("%v: unexpected error: %v", args, err)
+		}
+	}
+	if f := config.LoadFile(); f.Scheme != "https" || !f.SkipVerify() {
+		t.Errorf("expected TLS settings saved, got %+v", f.Config)
+	}
+
+	// An explicit false turns verification back on, over the saved true
+	if _, err := executeCmd("list", "rooms", "--no-cache", "--insecure-skip-verify=false"); err == nil {
+		t.Fatal("expected certificate error with verification back on")
+	}
+	if _, err := executeCmd("config", "set", "--insecure-skip-verify=false"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f := config.LoadFile(); f.InsecureSkipVerify == nil || f.SkipVerify() {
+		t.Errorf("expected an explicit false saved, got %+v", f.Config)
+	}
+	if _, err := executeCmd("list", "rooms", "--no-cache"); err == nil {
+		t.Fatal("expected certificate error after config set --insecure-skip-verify=false")
+	}
+	if _, err := executeCmd("list", "rooms", "--insecure-skip-verify=maybe"); err == nil {
+		t.Fatal("expected error for an invalid boolean")
+	}
+}
+
+func TestConfigSetTokenPerProfile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(config.ProfileEnv, "")
+	t.Setenv(config.TokenEnv, "")
+	t.Setenv(config.TokenFileEnv, "")
+
+	path := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(path, []byte("old"), 0600)
+	steps := [][]string{
+		{"config", "profile", "add", "lab", "--host", "lab.local", "--token-file", path},
+		{"config", "set", "--token", "home"},
+		{"config", "set", "--profile", "lab", "--token", "lab"},
+	}
+	for _, args := range steps {
+		if _, err := executeCmd(args...); err != nil {
+			t.Fatalf("%v: unexpected error: %v", args, err)
+		}
+	}
+
+	for profile, want := range map[string]string{"": "home", "lab": "lab"} {
+		r, err := config.Resolve(profile, config.Config{})
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", profile, err)
+		}
+		if r.Token != want {
+			t.Errorf("%q: expected token %q, got %q", profile, want, r.Token)
+		}
+	}
+	if f := config.LoadFile(); f.Profiles["lab"].TokenFile != "" {
+		t.Errorf("expected --token to replace the stored token file, got %+v", f.Profiles["lab"])
+	}
+}
+
+func TestOptionalBool(t *testing.T) {
+	var p *bool
+	b := optionalBool{&p}
+	if b.String() != "" || b.Type() != "bool" {
+		t.Errorf("unexpected unset value %q of type %s", b.String(), b.Type())
+	}
+	b.Set("false")
+	if p == nil || *p || b.String() != "false" {
+		t.Errorf("expected explicit false, got %q", b.String())
+	}
+	b.Set("")
+	if p != nil {
+		t.Error("expected empty value to unset")
+	}
+}
+
+func TestConfigProfileAddInvalidName(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	for _, name := range []string{"../../evil", "lab/one", ""} {
+		_, err := executeCmd("config", "profile", "add", name, "--host", "x")
+		if err == nil || !strings.Contains(err.Error(), "invalid profile name") {
+			t.Errorf("%q: expected invalid profile name error, got %v", name, err)
+		}
+	}
+	if f := config.LoadFile(); len(f.Profiles) != 0 {
+		t.Errorf("expected no profiles saved, got %v", f.Profiles)
+	}
+}
+
+func TestConfigSetTokenSaveError(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	os.MkdirAll(filepath.Join(tmp, ".config", "itsyhome", "token"), 0755)
+
+	if _, err := executeCmd("config", "set", "--token", "x"); err == nil {
+		t.Fatal("expected token save error")
+	}
+	if _, err := os.Stat(config.Path()); err == nil {
+		t.Error("config.json should not be written when saving the token fails")
+	}
+}
+
+func TestConfigProfileRemoveSystem(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	original := loadSystemFile
+	loadSystemFile = func() config.File {
+		return config.File{Profiles: map[string]config.Config{"lab": {Host: "lab.internal"}}}
+	}
+	defer func() { loadSystemFile = original }()
+
+	_, err := executeCmd("config", "profile", "remove", "lab")
+	if err == nil || !strings.Contains(err.Error(), "cannot be removed") {
+		t.Fatalf("expected system profile error, got %v", err)
+	}
+}
+
+func TestProfileFlag(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]string{{"name": "Office"}})
+	})
+	defer func() { profileName = "" }()
+
+	outputFormat = display.FormatTable
+	commands := [][]string{
+		{"list", "rooms"}, {"list", "devices"}, {"list", "scenes"}, {"list", "groups"},
+		{"status"}, {"info", "Lamp"}, {"on", "Lamp"}, {"watch"}, {"wait", "Lamp", "--until", "on=true"},
+	}
+	for _, args := range commands {
+		args = append(args, "--profile", "missing")
+		if _, err := executeCmd(args...); err == nil || err.Error() != `unknown profile "missing"` {
+			t.Errorf("%v: expected unknown profile error, got %v", args, err)
+		}
+	}
+}
+
+func TestConnectionOverrides(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]string{{"name": "Office"}})
+	}))
+	defer srv.Close()
+
+	// Config file points nowhere useful; flags and env must win
+	t.Setenv("HOME", t.TempDir())
+	config.Save(config.Config{Host: "127.0.0.1", Port: 1})
+
+	outputFormat = display.FormatTable
+	if _, err := executeCmd("list", "rooms", "--url", srv.URL); err != nil {
+		t.Fatalf("--url: unexpected error: %v", err)
+	}
+
+	host, port, _ := strings.Cut(strings.TrimPrefix(srv.URL, "http://"), ":")
+	if _, err := executeCmd("list", "rooms", "--host", host, "--port", port); err != nil {
+		t.Fatalf("--host/--port: unexpected error: %v", err)
+	}
+
+	t.Setenv(config.URLEnv, srv.URL)
+	if _, err := executeCmd("list", "rooms"); err != nil {
+		t.Fatalf("env: unexpected error: %v", err)
+	}
+	if _, err := executeCmd("config", "--port", "9000"); err != nil {
+		t.Fatalf("config: unexpected error: %v", err)
+	}
+
+	t.Setenv(config.PortEnv, "bogus")
+	if _, err := executeCmd("list", "rooms"); err == nil {
+		t.Fatal("expected error for invalid port env")
+	}
+}
+
+func TestConfigSetURL(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(config.ProfileEnv, "")
+
+	if _, err := executeCmd("config", "set", "--url", "https://mac.example.com"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := executeCmd("config", "profile", "add", "lab", "--url", "http://lab.local:1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f := config.LoadFile()
+	if f.URL != "https://mac.example.com" || f.Profiles["lab"].URL != "http://lab.local:1" {
+		t.Errorf("unexpected config file: %+v", f)
+	}
+}
+
+func TestConfigCmdFlagsOverrideFileURL(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(config.ProfileEnv, "")
+	config.Save(config.Config{URL: "http://127.0.0.1:1"})
+	outputFormat = display.FormatTable
+
+	var err error
+	out := captureStdout(t, func() {
+		_, err = executeCmd("config", "--host", "127.0.0.1", "--port", "18423")
+	})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	if !strings.Contains(out, "http://127.0.0.1:18423 (from host and port)") {
+		t.Errorf("expected flags to replace the file URL, got %q", out)
+	}
 }
 
 // --- root command tests ---
 
 func TestRootCmdHelp(t *testing.T) {
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("--help")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -811,7 +1446,7 @@ func TestRootCmdHelp(t *testing.T) {
 }
 
 func TestRootCmdVersion(t *testing.T) {
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("--version")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -819,7 +1454,7 @@ func TestRootCmdVersion(t *testing.T) {
 }
 
 func TestRootCmdUnknown(t *testing.T) {
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("nonexistent")
 	if err == nil {
 		t.Fatal("expected error for unknown command")
@@ -854,7 +1489,7 @@ func TestShowRoomStatusDeviceOff(t *testing.T) {
 		})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("status", "Office")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -868,7 +1503,7 @@ func TestShowRoomStatusNoState(t *testing.T) {
 		})
 	})
 
-	jsonOutput = false
+	outputFormat = display.FormatTable
 	_, err := executeCmd("status", "Office")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/spf13/cobra"
+)
+
+// completionTimeout bounds the requests made while completing.
+const completionTimeout = 2 * time.Second
+
+type completionData struct {
+	Rooms   []client.Room
+	Devices []client.Device
+	Groups  []client.Group
+	Scenes  []client.Scene
+}
+
+// loadCompletionData returns the rooms, devices, groups and scenes of the
+// configured server. Every TAB press runs a new process, so the list cache
+// is what keeps completion fast.
+func loadCompletionData() (*completionData, error) {
+	c, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+
+	d := &completionData{}
+	if d.Rooms, err = c.ListRoomsContext(ctx); err != nil {
+		return nil, err
+	}
+	if d.Devices, err = c.ListDevicesContext(ctx, ""); err != nil {
+		return nil, err
+	}
+	if d.Groups, err = c.ListGroupsContext(ctx); err != nil {
+		return nil, err
+	}
+	if d.Scenes, err = c.ListScenesContext(ctx); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+type completionKind int
+
+const (
+	completeRoomNames completionKind = iota
+	completeSceneNames
+	completeAllTargets
+)
+
+// completeNames returns the names of kind that start with toComplete,
+// ignoring case, each with a short description.
+func completeNames(kind completionKind, toComplete string) []string {
+	d, err := loadCompletionData()
+	if err != nil {
+		return nil
+	}
+
+	// Shells pass what was typed, which may start with an open quote
+	prefix := strings.ToLower(strings.TrimLeft(toComplete, `"'`))
+	var comps []string
+	add := func(name, desc string) {
+		if strings.HasPrefix(strings.ToLower(name), prefix) {
+			if os.Getenv(completionShellEnv) == "bash" {
+				name = bashEscaper.Replace(name)
+			}
+			comps = append(comps, name+"\t"+desc)
+		}
+	}
+
+	if kind == completeSceneNames {
+		for _, s := range d.Scenes {
+			add(s.Name, "scene")
+		}
+		return comps
+	}
+	for _, r := range d.Rooms {
+		add(r.Name, "room")
+	}
+	if kind == completeRoomNames {
+		return comps
+	}
+	for _, dev := range d.Devices {
+		add(deviceKey(dev.Room, dev.Name), dev.Type)
+	}
+	for _, g := range d.Groups {
+		add(deviceKey(g.Room, "group."+g.Name), "group")
+	}
+	for _, s := range d.Scenes {
+		add("scene."+s.Name, "scene")
+	}
+	return comps
+}
+
+// completeTarget completes the first argument of a command that takes a
+// single target.
+func completeTarget(kind completionKind) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeNames(kind, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeFlagTarget completes a flag whose value is a target.
+func completeFlagTarget(kind completionKind) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return completeNames(kind, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeValueArgs completes "<value> <target>". Flag parsing is disabled
+// on value commands, so flags arrive in args and are skipped here.
+func completeValueArgs(action string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		positional := 0
+		for _, a := range args {
+			if !strings.HasPrefix(a, "-") || negativeValue.MatchString(a) {
+				positional++
+			}
+		}
+		switch {
+		case positional == 0 && action == "temp":
+			var comps []string
+			for name, k := range whitePoints {
+				if strings.HasPrefix(name, strings.ToLower(toComplete)) {
+					comps = append(comps, fmt.Sprintf("%s\t%dK", name, k))
+				}
+			}
+			sort.Strings(comps)
+			return comps, cobra.ShellCompDirectiveNoFileComp
+		case positional == 1:
+			return completeNames(completeAllTargets, toComplete), cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completionShellEnv is set by the bash completion script, whose
+// completions have to be escaped by the program.
+const completionShellEnv = "ITSYHOME_COMPLETION_SHELL"
+
+var bashEscaper = strings.NewReplacer(
+	" ", `\ `, `\`, `\\`, `'`, `\'`, `"`, `\"`, "(", `\(`, ")", `\)`,
+	"&", `\&`, ";", `\;`, "|", `\|`, "<", `\<`, ">", `\>`, "$", `\$`, "`", "\\`", "!", `\!`,
+)
+
+// patchBashCompletion makes "completion bash" tell the program it is being
+// completed by bash. Cobra's bash script inserts completions as they are,
+// so names with spaces have to arrive escaped; zsh, fish and PowerShell
+// quote them themselves.
+func patchBashCompletion(root *cobra.Command) {
+	root.InitDefaultCompletionCmd()
+	for _, c := range root.Commands() {
+		if c.Name() != "completion" {
+			continue
+		}
+		for _, sh := range c.Commands() {
+			if sh.Name() != "bash" {
+				continue
+			}
+			sh.RunE = func(cmd *cobra.Command, args []string) error {
+				noDesc, _ := cmd.Flags().GetBool("no-descriptions")
+				buf := new(bytes.Buffer)
+				// Writing to a buffer cannot fail
+				cmd.Root().GenBashCompletionV2(buf, !noDesc)
+				script := strings.Replace(buf.String(), `requestComp="${words[0]} `, `requestComp="`+completionShellEnv+`=bash ${words[0]} `, 1)
+				_, err := cmd.OutOrStdout().Write([]byte(script))
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+)
+
+// completionServer serves a small home and counts list requests. fail
+// makes the given path return an error.
+func completionServer(t *testing.T, fail string) *int32 {
+	var lists int32
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == fail {
+			w.WriteHeader(500)
+			return
+		}
+		atomic.AddInt32(&lists, 1)
+		switch r.URL.Path {
+		case "/list/rooms":
+			json.NewEncoder(w).Encode([]client.Room{{Name: "Office"}, {Name: "Living Room"}})
+		case "/list/devices":
+			json.NewEncoder(w).Encode([]client.Device{
+				{Name: "Desk Lamp", Type: "light", Room: "Office"},
+				{Name: "Lamp", Type: "light", Room: "Living Room"},
+			})
+		case "/list/groups":
+			json.NewEncoder(w).Encode([]client.Group{{Name: "All Lights"}, {Name: "Desk", Room: "Office"}})
+		case "/list/scenes":
+			json.NewEncoder(w).Encode([]client.Scene{{Name: "Good Night"}})
+		}
+	})
+	return &lists
+}
+
+// completions runs the hidden __complete command and returns the
+// suggestions without the directive lines that follow them.
+func completions(t *testing.T, args ...string) []string {
+	t.Helper()
+	out, err := executeCmd(append([]string{"__complete"}, args...)...)
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", args, err)
+	}
+	var comps []string
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, ":") {
+			break
+		}
+		comps = append(comps, line)
+	}
+	return comps
+}
+
+func TestCompleteTargets(t *testing.T) {
+	lists := completionServer(t, "")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"on", "off"}, "Office\troom|Office/Desk Lamp\tlight|Office/group.Desk\tgroup"},
+		{[]string{"info", `"liv`}, "Living Room\troom|Living Room/Lamp\tlight"},
+		{[]string{"watch", "g"}, "group.All Lights\tgroup"},
+		{[]string{"wait", "sc"}, "scene.Good Night\tscene"},
+		{[]string{"scene", ""}, "Good Night\tscene"},
+		{[]string{"status", ""}, "Office\troom|Living Room\troom"},
+		{[]string{"list", "devices", "o"}, "Office\troom"},
+		{[]string{"on", "-t", "Living Room/"}, "Living Room/Lamp\tlight"},
+		{[]string{"on", "Office", ""}, ""},
+		{[]string{"info", "Garage"}, ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(completions(t, tt.args...), "|"); got != tt.want {
+			t.Errorf("%v: expected %q, got %q", tt.args, tt.want, got)
+		}
+	}
+	if n := atomic.LoadInt32(lists); n != 4 {
+		t.Errorf("expected one fetch of each list, got %d requests", n)
+	}
+}
+
+func TestCompleteValueArgs(t *testing.T) {
+	completionServer(t, "")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"temp", ""}, "cool\t5000K|daylight\t6500K|neutral\t4000K|warm\t2700K"},
+		{[]string{"temp", "--force", "W"}, "warm\t2700K"},
+		{[]string{"brightness", ""}, ""},
+		{[]string{"brightness", "-10", "Office/"}, "Office/Desk Lamp\tlight|Office/group.Desk\tgroup"},
+		{[]string{"color", "red", "liv"}, "Living Room\troom|Living Room/Lamp\tlight"},
+		{[]string{"brightness", "50", "Office", ""}, ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(completions(t, tt.args...), "|"); got != tt.want {
+			t.Errorf("%v: expected %q, got %q", tt.args, tt.want, got)
+		}
+	}
+}
+
+func TestCompleteEscapesForBash(t *testing.T) {
+	completionServer(t, "")
+	t.Setenv(completionShellEnv, "bash")
+
+	got := strings.Join(completions(t, "on", "Living Room/"), "|")
+	if got != `Living\ Room/Lamp`+"\tlight" {
+		t.Errorf("unexpected completions: %q", got)
+	}
+	if got := bashEscaper.Replace(`Tom's "Lamp" (1) & $x`); got != `Tom\'s\ \"Lamp\"\ \(1\)\ \&\ \$x` {
+		t.Errorf("unexpected escaping: %s", got)
+	}
+}
+
+func TestCompleteErrors(t *testing.T) {
+	for _, fail := range []string{"/list/rooms", "/list/devices", "/list/groups", "/list/scenes"} {
+		completionServer(t, fail)
+		if got := completions(t, "on", ""); len(got) != 0 {
+			t.Errorf("%s: expected no completions, got %q", fail, got)
+		}
+	}
+
+	setupTestEnv(t, nil)
+	t.Setenv("ITSYHOME_PORT", "bogus")
+	if got := completions(t, "on", ""); len(got) != 0 {
+		t.Errorf("expected no completions, got %q", got)
+	}
+}
+
+func TestCompleteClientError(t *testing.T) {
+	setupTestEnv(t, nil)
+	t.Setenv("ITSYHOME_URL", "ftp://nowhere")
+	if got := completions(t, "on", ""); len(got) != 0 {
+		t.Errorf("expected no completions, got %q", got)
+	}
+}
+
+func TestBashCompletionScript(t *testing.T) {
+	patchBashCompletion(rootCmd)
+
+	for _, args := range [][]string{{"completion", "bash"}, {"completion", "bash", "--no-descriptions"}} {
+		out, err := executeCmd(args...)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", args, err)
+		}
+		if !strings.Contains(out, `requestComp="ITSYHOME_COMPLETION_SHELL=bash ${words[0]} __complete`) {
+			t.Errorf("%v: expected the patched request line", args)
+		}
+	}
+}
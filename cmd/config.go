@@ -2,47 +2,261 @@ package cmd
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/nickustinov/itsyhome-cli/internal/config"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	saveConfigFile = config.SaveFile
+	loadSystemFile = config.LoadSystemFile
 )
 
 var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Show or update CLI configuration",
-	Run: func(cmd *cobra.Command, args []string) {
-		cfg := config.Load()
-		fmt.Printf("Host: %s\n", cfg.Host)
-		fmt.Printf("Port: %d\n", cfg.Port)
-		fmt.Printf("URL:  %s\n", cfg.BaseURL())
-		fmt.Printf("File: %s\n", config.Path())
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := resolveConfig()
+		if err != nil {
+			return err
+		}
+		urlSource := r.Sources["url"]
+		if r.URL == "" {
+			urlSource = "from host and port"
+		}
+		fmt.Printf("Profile: %s\n", r.Profile)
+		fmt.Printf("Host:    %s (%s)\n", r.Host, r.Sources["host"])
+		fmt.Printf("Port:    %d (%s)\n", r.Port, r.Sources["port"])
+		fmt.Printf("URL:     %s (%s)\n", r.BaseURL(), urlSource)
+		if r.Token != "" {
+			fmt.Printf("Token:   set (%s)\n", r.Sources["token"])
+		} else {
+			fmt.Println("Token:   not set")
+		}
+		if r.CACert != "" {
+			fmt.Printf("CA:      %s (%s)\n", r.CACert, r.Sources["ca_cert"])
+		}
+		if r.ClientCert != "" {
+			fmt.Printf("Cert:    %s (%s)\n", r.ClientCert, r.Sources["client_cert"])
+		}
+		if r.SkipVerify() {
+			fmt.Printf("TLS:     certificate verification disabled (%s)\n", r.Sources["insecure_skip_verify"])
+		}
+		fmt.Printf("File:    %s\n", config.Path())
+		fmt.Printf("System:  %s\n", config.SystemPath())
+		return nil
 	},
 }
 
 var configSetCmd = &cobra.Command{
 	Use:   "set",
-	Short: "Set configuration values",
-	Run: func(cmd *cobra.Command, args []string) {
-		cfg := config.Load()
-
-		if host, _ := cmd.Flags().GetString("host"); host != "" {
-			cfg.Host = host
+	Short: "Set configuration values for the active profile",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		merged := config.LoadMerged()
+		name := merged.ResolveProfile(profileName)
+		if _, ok := merged.Lookup(name); !ok {
+			return fmt.Errorf("unknown profile %q", name)
 		}
-		if port, _ := cmd.Flags().GetInt("port"); port != 0 {
-			cfg.Port = port
+
+		// Only store what the user sets so system-wide defaults still apply
+		cfg, _ := config.LoadFile().Lookup(name)
+		cfg = cfg.Merge(setFlags)
+
+		// The token goes to the profile's own 0600 file rather than
+		// config.json, replacing any token or token_file stored there
+		if token, _ := cmd.Flags().GetString("token"); token != "" {
+			if err := config.SaveToken(name, token); err != nil {
+				return err
+			}
+			cfg.Token, cfg.TokenFile = "", ""
 		}
 
-		if err := config.Save(cfg); err != nil {
-			fmt.Printf("Error: %s\n", err)
-			return
+		if err := config.SaveProfile(name, cfg); err != nil {
+			return err
 		}
 		fmt.Println("Configuration saved.")
+		return nil
 	},
 }
 
+var configProfileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage named connection profiles",
+}
+
+var configProfileAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add a connection profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if err := config.CheckProfileName(name); err != nil {
+			return err
+		}
+		if _, ok := config.LoadMerged().Lookup(name); ok {
+			return fmt.Errorf("profile %q already exists", name)
+		}
+
+		f := config.LoadFile()
+		if f.Profiles == nil {
+			f.Profiles = map[string]config.Config{}
+		}
+		f.Profiles[name] = profileAddFlags
+		if err := saveConfigFile(f); err != nil {
+			return err
+		}
+		fmt.Printf("Profile %q added.\n", name)
+		return nil
+	},
+}
+
+var configProfileUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Set the active connection profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if _, err := config.LoadMerged().Profile(name); err != nil {
+			return err
+		}
+
+		f := config.LoadFile()
+		f.ActiveProfile = name
+		if name == config.DefaultProfile {
+			f.ActiveProfile = ""
+		}
+		if err := saveConfigFile(f); err != nil {
+			return err
+		}
+		fmt.Printf("Using profile %q.\n", name)
+		return nil
+	},
+}
+
+var configProfileRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a connection profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if name == config.DefaultProfile {
+			return fmt.Errorf("cannot remove the default profile")
+		}
+		f := config.LoadFile()
+		if _, ok := f.Profiles[name]; !ok {
+			if _, ok := loadSystemFile().Profiles[name]; ok {
+				return fmt.Errorf("profile %q is defined in %s and cannot be removed", name, config.SystemPath())
+			}
+			return fmt.Errorf("unknown profile %q", name)
+		}
+
+		delete(f.Profiles, name)
+		if f.ActiveProfile == name {
+			f.ActiveProfile = ""
+		}
+		if err := saveConfigFile(f); err != nil {
+			return err
+		}
+		fmt.Printf("Profile %q removed.\n", name)
+		return nil
+	},
+}
+
+type profileEntry struct {
+	Name   string `json:"name"`
+	Host   string `json:"host"`
+	Port   int    `json:"port"`
+	Active bool   `json:"active"`
+}
+
+var configProfileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List connection profiles",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f := config.LoadMerged()
+		active := f.ResolveProfile(profileName)
+
+		names := f.ProfileNames()
+		entries := make([]profileEntry, len(names))
+		for i, name := range names {
+			cfg, _ := f.Profile(name)
+			entries[i] = profileEntry{Name: name, Host: cfg.Host, Port: cfg.Port, Active: name == active}
+		}
+
+		tbl := display.NewTable("Profile", "Host", "Port", "Active")
+		for _, e := range entries {
+			marker := ""
+			if e.Active {
+				marker = "*"
+			}
+			tbl.AddRow(e.Name, e.Host, fmt.Sprintf("%d", e.Port), marker)
+		}
+		return render(display.Output{Data: entries, Table: tbl})
+	},
+}
+
+var (
+	setFlags        config.Config
+	profileAddFlags config.Config
+)
+
+// addConnectionFlags binds the connection settings that can be given on the
+// command line, both for one-off overrides and for config set/profile add.
+func addConnectionFlags(fs *pflag.FlagSet, cfg *config.Config) {
+	fs.StringVar(&cfg.Host, "host", "", "Server host address")
+	fs.IntVar(&cfg.Port, "port", 0, "Server port")
+	fs.StringVar(&cfg.Scheme, "scheme", "", "Server scheme: http or https")
+	fs.StringVar(&cfg.URL, "url", "", "Server base URL, e.g. https://mac.local:8423 (takes precedence over host and port)")
+	fs.StringVar(&cfg.TokenFile, "token-file", "", "Read the API token from this file (must be mode 0600)")
+	fs.StringVar(&cfg.CACert, "ca-cert", "", "PEM bundle of CAs to trust instead of the system roots")
+	fs.StringVar(&cfg.ClientCert, "client-cert", "", "PEM client certificate for mutual TLS")
+	fs.StringVar(&cfg.ClientKey, "client-key", "", "PEM private key for --client-cert")
+	fs.VarPF(optionalBool{&cfg.InsecureSkipVerify}, "insecure-skip-verify", "",
+		"Do not verify the server certificate (lab setups only); =false turns verification back on").NoOptDefVal = "true"
+}
+
+// optionalBool is a boolean flag that stays nil until it is given, so an
+// explicit false can override a true from a lower layer. Setting it to ""
+// unsets it again.
+type optionalBool struct{ p **bool }
+
+func (b optionalBool) String() string {
+	if *b.p == nil {
+		return ""
+	}
+	return strconv.FormatBool(**b.p)
+}
+
+func (b optionalBool) Set(s string) error {
+	if s == "" {
+		*b.p = nil
+		return nil
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*b.p = &v
+	return nil
+}
+
+func (b optionalBool) Type() string { return "bool" }
+
 func init() {
-	configSetCmd.Flags().String("host", "", "Server host address")
-	configSetCmd.Flags().Int("port", 0, "Server port")
+	addConnectionFlags(configSetCmd.Flags(), &setFlags)
+	configSetCmd.Flags().String("token", "", "API token, stored in a separate 0600 secret file per profile")
+	configSetCmd.MarkFlagsMutuallyExclusive("token", "token-file")
 	configCmd.AddCommand(configSetCmd)
+
+	addConnectionFlags(configProfileAddCmd.Flags(), &profileAddFlags)
+	configProfileCmd.AddCommand(configProfileAddCmd)
+	configProfileCmd.AddCommand(configProfileUseCmd)
+	configProfileCmd.AddCommand(configProfileRemoveCmd)
+	configProfileCmd.AddCommand(configProfileListCmd)
+	configCmd.AddCommand(configProfileCmd)
+
 	rootCmd.AddCommand(configCmd)
 }
@@ -1,72 +1,201 @@
 package cmd
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"strings"
+	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/nickustinov/itsyhome-cli/internal/client"
-	"github.com/nickustinov/itsyhome-cli/internal/config"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
 	"github.com/spf13/cobra"
 )
 
-func makeControlCmd(action, short string, minArgs int) *cobra.Command {
-	return &cobra.Command{
+func makeControlCmd(action, short string) *cobra.Command {
+	var (
+		sched schedule
+		tf    targetFlags
+	)
+	cmd := &cobra.Command{
 		Use:   fmt.Sprintf("%s <target>", action),
 		Short: short,
-		Args:  cobra.MinimumNArgs(minArgs),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			target := strings.Join(args, " ")
-			path := "/" + action + "/" + target
-			return doControl(path)
+			targets, err := tf.resolve(cmd, args)
+			if err != nil {
+				return err
+			}
+			return runScheduled(cmd.Context(), sched, action, targets, func(ctx context.Context) error {
+				if len(targets) == 1 {
+					return doControl(ctx, action, "", targets[0])
+				}
+				return controlTargets(ctx, targets, func(ctx context.Context, c *client.Client, target string) (string, error) {
+					resp, err := c.Control(ctx, action, target)
+					if err != nil {
+						return "", err
+					}
+					return resp.Status, nil
+				})
+			})
 		},
 	}
+	kind := completeAllTargets
+	if action == "scene" {
+		kind = completeSceneNames
+	}
+	cmd.ValidArgsFunction = completeTarget(kind)
+	addTargetFlags(cmd, &tf, action != "scene")
+	cmd.RegisterFlagCompletionFunc("target", completeFlagTarget(kind))
+	addScheduleFlags(cmd, &sched)
+	return cmd
 }
 
 func makeValueControlCmd(action, short, valueDesc string) *cobra.Command {
-	return &cobra.Command{
+	var (
+		force bool
+		tr    transition
+		sched schedule
+		tf    targetFlags
+	)
+	cmd := &cobra.Command{
 		Use:   fmt.Sprintf("%s <%s> <target>", action, valueDesc),
 		Short: short,
-		Args:  cobra.MinimumNArgs(2),
+		// Flags are parsed in parseValueArgs so "-50" is read as a value
+		DisableFlagParsing: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			args, err := parseValueArgs(cmd, args)
+			if err != nil {
+				return err
+			}
+			if help, _ := cmd.Flags().GetBool("help"); help {
+				return cmd.Help()
+			}
+			if err := cobra.MinimumNArgs(1)(cmd, args); err != nil {
+				return err
+			}
+			targets, err := tf.resolve(cmd, args[1:])
+			if err != nil {
+				return err
+			}
+
 			value := args[0]
-			target := strings.Join(args[1:], " ")
-			path := "/" + action + "/" + value + "/" + target
-			return doControl(path)
+			if parse, ok := valueParsers[action]; ok {
+				if value, err = parse(value); err != nil {
+					return err
+				}
+			}
+			relative := isRelative(value)
+			if validate, ok := valueValidators[action]; ok && !force && !relative {
+				v, err := validate(value)
+				if err != nil {
+					return err
+				}
+				value = v
+			}
+			if transitionActions[action] {
+				if err := tr.validate(); err != nil {
+					return err
+				}
+				if _, err := strconv.Atoi(value); err != nil && tr.over > 0 && !relative {
+					return fmt.Errorf("--over needs a whole number, got %q", value)
+				}
+			}
+
+			r, adjust := valueRanges[action]
+			adjust = adjust && (relative || tr.over > 0)
+			return runScheduled(cmd.Context(), sched, action, targets, func(ctx context.Context) error {
+				if len(targets) == 1 {
+					if adjust {
+						return doAdjust(ctx, action, r, value, targets[0], force, tr)
+					}
+					return doControl(ctx, action, value, targets[0])
+				}
+				return controlTargets(ctx, targets, func(ctx context.Context, c *client.Client, target string) (string, error) {
+					if adjust {
+						results, err := adjustTarget(ctx, c, action, r, value, target, force, tr)
+						if err != nil {
+							return "", err
+						}
+						return adjustStatus(results)
+					}
+					resp, err := c.ControlValue(ctx, action, value, target)
+					if err != nil {
+						return "", err
+					}
+					return resp.Status, nil
+				})
+			})
 		},
 	}
+	cmd.ValidArgsFunction = completeValueArgs(action)
+	cmd.Flags().BoolVar(&force, "force", false, "Send the value as-is without range checks")
+	addTargetFlags(cmd, &tf, true)
+	addScheduleFlags(cmd, &sched)
+	if transitionActions[action] {
+		cmd.Flags().DurationVar(&tr.over, "over", 0, "Fade to the value over this long, e.g. 10m")
+		cmd.Flags().StringVar(&tr.curve, "curve", "linear", "Transition curve: "+curveNames())
+		cmd.Flags().DurationVar(&tr.step, "step", time.Second, "Time between updates during --over")
+	}
+	return cmd
+}
+
+var negativeValue = regexp.MustCompile(`^-\d+(\.\d+)?%?$`)
+
+// parseValueArgs parses the flags of a value control command. The first
+// argument that looks like a negative number is kept as a positional value
+// instead of being read as a shorthand flag.
+func parseValueArgs(cmd *cobra.Command, args []string) ([]string, error) {
+	var value []string
+	rest := make([]string, 0, len(args))
+	for _, a := range args {
+		if value == nil && negativeValue.MatchString(a) {
+			value = []string{a}
+			continue
+		}
+		rest = append(rest, a)
+	}
+
+	fs := cmd.Flags()
+	fs.AddFlagSet(cmd.InheritedFlags())
+	if err := fs.Parse(rest); err != nil {
+		return nil, err
+	}
+	return append(value, fs.Args()...), nil
 }
 
-func doControl(path string) error {
-	c := client.New(config.Load())
-	resp, err := c.DoAction(path)
+func doControl(ctx context.Context, action, value, target string) error {
+	c, err := newClient()
 	if err != nil {
 		return err
 	}
-
-	if jsonOutput {
-		data, _ := json.MarshalIndent(resp, "", "  ")
-		fmt.Println(string(data))
-		return nil
+	var resp *client.ActionResponse
+	if value == "" {
+		resp, err = c.Control(ctx, action, target)
+	} else {
+		resp, err = c.ControlValue(ctx, action, value, target)
+	}
+	if err != nil {
+		return err
 	}
 
-	fmt.Println(resp.Status)
-	return nil
+	tbl := display.NewTable("Target", "Status")
+	tbl.AddRow(target, resp.Status)
+	return render(display.Output{Data: resp, Table: tbl, Text: resp.Status + "\n"})
 }
 
 func init() {
-	rootCmd.AddCommand(makeControlCmd("toggle", "Toggle a device or group", 1))
-	rootCmd.AddCommand(makeControlCmd("on", "Turn on a device or group", 1))
-	rootCmd.AddCommand(makeControlCmd("off", "Turn off a device or group", 1))
-	rootCmd.AddCommand(makeControlCmd("lock", "Lock a device", 1))
-	rootCmd.AddCommand(makeControlCmd("unlock", "Unlock a device", 1))
-	rootCmd.AddCommand(makeControlCmd("open", "Open a device (blinds, garage)", 1))
-	rootCmd.AddCommand(makeControlCmd("close", "Close a device (blinds, garage)", 1))
-	rootCmd.AddCommand(makeControlCmd("scene", "Activate a scene", 1))
+	rootCmd.AddCommand(makeControlCmd("toggle", "Toggle a device or group"))
+	rootCmd.AddCommand(makeControlCmd("on", "Turn on a device or group"))
+	rootCmd.AddCommand(makeControlCmd("off", "Turn off a device or group"))
+	rootCmd.AddCommand(makeControlCmd("lock", "Lock a device"))
+	rootCmd.AddCommand(makeControlCmd("unlock", "Unlock a device"))
+	rootCmd.AddCommand(makeControlCmd("open", "Open a device (blinds, garage)"))
+	rootCmd.AddCommand(makeControlCmd("close", "Close a device (blinds, garage)"))
+	rootCmd.AddCommand(makeControlCmd("scene", "Activate a scene"))
 
 	rootCmd.AddCommand(makeValueControlCmd("brightness", "Set brightness (0-100)", "value"))
 	rootCmd.AddCommand(makeValueControlCmd("position", "Set position (0-100)", "value"))
 	rootCmd.AddCommand(makeValueControlCmd("speed", "Set fan speed (0-100)", "value"))
-	rootCmd.AddCommand(makeValueControlCmd("temp", "Set color temperature (140-500 mireds)", "value"))
-	rootCmd.AddCommand(makeValueControlCmd("color", "Set color (hex)", "hex"))
+	rootCmd.AddCommand(makeValueControlCmd("temp", "Set color temperature (140-500 mireds, 2700K, warm, neutral, cool, daylight)", "value"))
+	rootCmd.AddCommand(makeValueControlCmd("color", "Set color (hex, CSS name, rgb() or hsl())", "color"))
 }
@@ -1,45 +1,49 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
 
 	"github.com/nickustinov/itsyhome-cli/internal/client"
-	"github.com/nickustinov/itsyhome-cli/internal/config"
+	"github.com/nickustinov/itsyhome-cli/internal/color"
 	"github.com/nickustinov/itsyhome-cli/internal/display"
 	"github.com/spf13/cobra"
 )
 
 var infoCmd = &cobra.Command{
-	Use:   "info <device|room|group>",
-	Short: "Show detailed info about a device, room, or group",
-	Args:  cobra.MinimumNArgs(1),
+	Use:               "info <device|room|group>",
+	Short:             "Show detailed info about a device, room, or group",
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeTarget(completeAllTargets),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		target := strings.Join(args, " ")
-		c := client.New(config.Load())
-		infos, err := c.GetInfo(target)
+		c, err := newClient()
 		if err != nil {
 			return err
 		}
-
-		if jsonOutput {
-			data, _ := json.MarshalIndent(infos, "", "  ")
-			fmt.Println(string(data))
-			return nil
+		infos, err := c.GetInfoContext(cmd.Context(), target)
+		if err != nil {
+			return err
 		}
 
+		var data interface{} = infos
+		tbl := multiInfoTable(infos)
 		if len(infos) == 1 {
-			printSingleInfo(infos[0])
-		} else {
-			printMultiInfo(infos)
+			tbl = singleInfoTable(infos[0])
+			// Templates, JSONPath and --field address a single device
+			// directly, e.g. jsonpath={.state.on}. The serialised formats
+			// keep the list so scripts reading [0] work for any target.
+			switch outputFormat.Kind() {
+			case display.FormatGoTemplate, display.FormatJSONPath, display.FormatField:
+				data = infos[0]
+			}
 		}
-		return nil
+		return render(display.Output{Data: data, Table: tbl})
 	},
 }
 
-func printSingleInfo(info client.DeviceInfo) {
+func singleInfoTable(info client.DeviceInfo) *display.Table {
 	tbl := display.NewTable("Property", "Value")
 	tbl.AddRow("Name", info.Name)
 	tbl.AddRow("Type", info.Type)
@@ -59,13 +63,38 @@ func printSingleInfo(info client.DeviceInfo) {
 		}
 		sort.Strings(keys)
 		for _, k := range keys {
-			tbl.AddRow(k, fmt.Sprintf("%v", info.State[k]))
+			value := fmt.Sprintf("%v", info.State[k])
+			if k == "colorTemperature" {
+				value += fmt.Sprintf(" (%dK)", miredsToKelvin(toFloat(info.State[k])))
+			}
+			tbl.AddRow(k, value)
 		}
+		if c, ok := stateColor(info); ok {
+			value := "#" + c.Hex()
+			if swatch := display.Swatch(c.R, c.G, c.B); swatch != "" {
+				value = swatch + " " + value
+			}
+			tbl.AddRow("color", value)
+		}
+	}
+	return tbl
+}
+
+// stateColor converts the hue and saturation a device reports to RGB at
+// full brightness.
+func stateColor(info client.DeviceInfo) (color.RGB, bool) {
+	hue, ok := info.State["hue"]
+	if !ok {
+		return color.RGB{}, false
+	}
+	sat := 100.0
+	if s, ok := info.State["saturation"]; ok {
+		sat = toFloat(s)
 	}
-	fmt.Print(tbl.Render())
+	return color.FromHSV(toFloat(hue), sat/100, 1), true
 }
 
-func printMultiInfo(infos []client.DeviceInfo) {
+func multiInfoTable(infos []client.DeviceInfo) *display.Table {
 	tbl := display.NewTable("Device", "Type", "State", "Value")
 	for _, info := range infos {
 		state := "off"
@@ -79,7 +108,7 @@ func printMultiInfo(infos []client.DeviceInfo) {
 		}
 		tbl.AddRow(info.Name, info.Type, state, formatValue(info))
 	}
-	fmt.Print(tbl.Render())
+	return tbl
 }
 
 func init() {
@@ -1,12 +1,8 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 
-	"github.com/nickustinov/itsyhome-cli/internal/client"
-	"github.com/nickustinov/itsyhome-cli/internal/config"
-	"github.com/nickustinov/itsyhome-cli/internal/display"
 	"github.com/spf13/cobra"
 )
 
@@ -15,62 +11,68 @@ var listCmd = &cobra.Command{
 	Short: "List rooms, devices, scenes, or groups",
 }
 
+var (
+	roomColumns   = []listColumn{{"name", "Room"}}
+	deviceColumns = []listColumn{{"name", "Device"}, {"type", "Type"}, {"room", "Room"}, {"status", "Status"}}
+	sceneColumns  = []listColumn{{"name", "Scene"}}
+	groupColumns  = []listColumn{{"name", "Group"}, {"room", "Room"}, {"icon", "Icon"}, {"devices", "Devices"}}
+
+	listRoomsOpts   listOptions
+	listDevicesOpts listOptions
+	listScenesOpts  listOptions
+	listGroupsOpts  listOptions
+)
+
 var listRoomsCmd = &cobra.Command{
 	Use:   "rooms",
 	Short: "List all rooms",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		c := client.New(config.Load())
-		rooms, err := c.ListRooms()
+		c, err := newClient()
 		if err != nil {
 			return err
 		}
-
-		if jsonOutput {
-			data, _ := json.MarshalIndent(rooms, "", "  ")
-			fmt.Println(string(data))
-			return nil
+		rooms, err := c.ListRoomsContext(cmd.Context())
+		if err != nil {
+			return err
 		}
 
-		tbl := display.NewTable("Room")
-		for _, r := range rooms {
-			tbl.AddRow(r.Name)
+		rows := make([]listRow, len(rooms))
+		for i, r := range rooms {
+			rows[i] = listRow{item: r, values: []string{r.Name}}
 		}
-		fmt.Print(tbl.Render())
-		return nil
+		return listRoomsOpts.render(roomColumns, rows)
 	},
 }
 
 var listDevicesCmd = &cobra.Command{
-	Use:   "devices [room]",
-	Short: "List devices, optionally filtered by room",
+	Use:               "devices [room]",
+	Short:             "List devices, optionally filtered by room",
+	ValidArgsFunction: completeTarget(completeRoomNames),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		c := client.New(config.Load())
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
 		room := ""
 		if len(args) > 0 {
 			room = args[0]
 		}
 
-		devices, err := c.ListDevices(room)
+		// Reachability changes too often to trust a cached device list
+		devices, err := c.Fresh().ListDevicesContext(cmd.Context(), room)
 		if err != nil {
 			return err
 		}
 
-		if jsonOutput {
-			data, _ := json.MarshalIndent(devices, "", "  ")
-			fmt.Println(string(data))
-			return nil
-		}
-
-		tbl := display.NewTable("Device", "Type", "Room", "Status")
-		for _, d := range devices {
+		rows := make([]listRow, len(devices))
+		for i, d := range devices {
 			status := "ok"
 			if !d.Reachable {
 				status = "unreachable"
 			}
-			tbl.AddRow(d.Name, d.Type, d.Room, status)
+			rows[i] = listRow{item: d, values: []string{d.Name, d.Type, d.Room, status}}
 		}
-		fmt.Print(tbl.Render())
-		return nil
+		return listDevicesOpts.render(deviceColumns, rows)
 	},
 }
 
@@ -78,24 +80,20 @@ var listScenesCmd = &cobra.Command{
 	Use:   "scenes",
 	Short: "List all scenes",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		c := client.New(config.Load())
-		scenes, err := c.ListScenes()
+		c, err := newClient()
 		if err != nil {
 			return err
 		}
-
-		if jsonOutput {
-			data, _ := json.MarshalIndent(scenes, "", "  ")
-			fmt.Println(string(data))
-			return nil
+		scenes, err := c.ListScenesContext(cmd.Context())
+		if err != nil {
+			return err
 		}
 
-		tbl := display.NewTable("Scene")
-		for _, s := range scenes {
-			tbl.AddRow(s.Name)
+		rows := make([]listRow, len(scenes))
+		for i, s := range scenes {
+			rows[i] = listRow{item: s, values: []string{s.Name}}
 		}
-		fmt.Print(tbl.Render())
-		return nil
+		return listScenesOpts.render(sceneColumns, rows)
 	},
 }
 
@@ -103,32 +101,33 @@ var listGroupsCmd = &cobra.Command{
 	Use:   "groups",
 	Short: "List all groups",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		c := client.New(config.Load())
-		groups, err := c.ListGroups()
+		c, err := newClient()
 		if err != nil {
 			return err
 		}
-
-		if jsonOutput {
-			data, _ := json.MarshalIndent(groups, "", "  ")
-			fmt.Println(string(data))
-			return nil
+		groups, err := c.ListGroupsContext(cmd.Context())
+		if err != nil {
+			return err
 		}
 
-		tbl := display.NewTable("Group", "Room", "Icon", "Devices")
-		for _, g := range groups {
+		rows := make([]listRow, len(groups))
+		for i, g := range groups {
 			room := g.Room
 			if room == "" {
 				room = "(global)"
 			}
-			tbl.AddRow(g.Name, room, g.Icon, fmt.Sprintf("%d", g.Devices))
+			rows[i] = listRow{item: g, values: []string{g.Name, room, g.Icon, fmt.Sprintf("%d", g.Devices)}}
 		}
-		fmt.Print(tbl.Render())
-		return nil
+		return listGroupsOpts.render(groupColumns, rows)
 	},
 }
 
 func init() {
+	addListFlags(listRoomsCmd, &listRoomsOpts, roomColumns)
+	addListFlags(listDevicesCmd, &listDevicesOpts, deviceColumns)
+	addListFlags(listScenesCmd, &listScenesOpts, sceneColumns)
+	addListFlags(listGroupsCmd, &listGroupsOpts, groupColumns)
+
 	listCmd.AddCommand(listRoomsCmd)
 	listCmd.AddCommand(listDevicesCmd)
 	listCmd.AddCommand(listScenesCmd)
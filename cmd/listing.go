@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+	"github.com/spf13/cobra"
+)
+
+// listColumn is one column of a list command. key is the name used by
+// --sort, --filter and --columns.
+type listColumn struct {
+	key    string
+	header string
+}
+
+// listRow is one result of a list command: the item printed by the
+// structured formats and its table cells, one per column.
+type listRow struct {
+	item   interface{}
+	values []string
+}
+
+// listOptions holds the flags every list command shares.
+type listOptions struct {
+	sort        string
+	reverse     bool
+	filters     []string
+	unreachable bool
+	columns     []string
+	noHeaders   bool
+}
+
+// addListFlags adds the sorting, filtering and column flags. --unreachable
+// is only offered by commands whose rows have a status column.
+func addListFlags(cmd *cobra.Command, o *listOptions, cols []listColumn) {
+	keys := orList(columnKeys(cols))
+	cmd.Flags().StringVar(&o.sort, "sort", "", "Sort by "+keys)
+	cmd.Flags().BoolVar(&o.reverse, "reverse", false, "Reverse the order")
+	cmd.Flags().StringArrayVar(&o.filters, "filter", nil, "Only show rows where key=glob, e.g. type=light (repeatable; keys "+keys+")")
+	cmd.Flags().StringSliceVar(&o.columns, "columns", nil, "Comma-separated columns to show, in order ("+keys+")")
+	cmd.Flags().BoolVar(&o.noHeaders, "no-headers", false, "Leave out the header row")
+	if columnIndex(cols, "status") >= 0 {
+		cmd.Flags().BoolVar(&o.unreachable, "unreachable", false, "Only show unreachable devices (same as --filter status=unreachable)")
+	}
+	complete := cobra.FixedCompletions(columnKeys(cols), cobra.ShellCompDirectiveNoFileComp)
+	cmd.RegisterFlagCompletionFunc("sort", complete)
+	cmd.RegisterFlagCompletionFunc("columns", complete)
+}
+
+func columnKeys(cols []listColumn) []string {
+	keys := make([]string, len(cols))
+	for i, c := range cols {
+		keys[i] = c.key
+	}
+	return keys
+}
+
+func columnIndex(cols []listColumn, key string) int {
+	for i, c := range cols {
+		if c.key == strings.ToLower(key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// orList joins words as "a, b or c".
+func orList(words []string) string {
+	if len(words) == 1 {
+		return words[0]
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " or " + words[len(words)-1]
+}
+
+type listFilter struct {
+	col     int
+	pattern string
+}
+
+func parseListFilters(cols []listColumn, values []string) ([]listFilter, error) {
+	var filters []listFilter
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			key, pattern, ok := strings.Cut(part, "=")
+			key = strings.TrimSpace(key)
+			pattern = strings.TrimSpace(pattern)
+			if !ok || key == "" {
+				return nil, fmt.Errorf("invalid --filter %q: expected key=value", part)
+			}
+			col := columnIndex(cols, key)
+			if col < 0 {
+				return nil, fmt.Errorf("unknown --filter key %q (use %s)", key, orList(columnKeys(cols)))
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid --filter %q: %w", part, err)
+			}
+			filters = append(filters, listFilter{col: col, pattern: strings.ToLower(pattern)})
+		}
+	}
+	return filters, nil
+}
+
+// lessCell orders numbers numerically and everything else ignoring case.
+func lessCell(a, b string) bool {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return x < y
+	}
+	return strings.ToLower(a) < strings.ToLower(b)
+}
+
+// render filters, sorts and prints rows. Filtering and sorting apply to
+// every output format; --columns and --no-headers only to the tabular ones.
+func (o *listOptions) render(cols []listColumn, rows []listRow) error {
+	values := o.filters
+	if o.unreachable {
+		values = append(values, "status=unreachable")
+	}
+	filters, err := parseListFilters(cols, values)
+	if err != nil {
+		return err
+	}
+
+	show := make([]int, len(cols))
+	for i := range show {
+		show[i] = i
+	}
+	if len(o.columns) > 0 {
+		show = show[:0]
+		for _, key := range o.columns {
+			i := columnIndex(cols, strings.TrimSpace(key))
+			if i < 0 {
+				return fmt.Errorf("unknown column %q (use %s)", key, orList(columnKeys(cols)))
+			}
+			show = append(show, i)
+		}
+	}
+
+	var selected []listRow
+	for _, row := range rows {
+		keep := true
+		for _, f := range filters {
+			if ok, _ := path.Match(f.pattern, strings.ToLower(row.values[f.col])); !ok {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			selected = append(selected, row)
+		}
+	}
+
+	if o.sort != "" {
+		col := columnIndex(cols, o.sort)
+		if col < 0 {
+			return fmt.Errorf("cannot sort by %q (use %s)", o.sort, orList(columnKeys(cols)))
+		}
+		sort.SliceStable(selected, func(i, j int) bool {
+			return lessCell(selected[i].values[col], selected[j].values[col])
+		})
+	}
+	if o.reverse {
+		for i, j := 0, len(selected)-1; i < j; i, j = i+1, j-1 {
+			selected[i], selected[j] = selected[j], selected[i]
+		}
+	}
+
+	headers := make([]string, len(show))
+	for i, col := range show {
+		headers[i] = cols[col].header
+	}
+	tbl := display.NewTable(headers...)
+	if o.noHeaders {
+		tbl.HideHeaders()
+	}
+	items := make([]interface{}, len(selected))
+	for i, row := range selected {
+		items[i] = row.item
+		cells := make([]string, len(show))
+		for j, col := range show {
+			cells[j] = row.values[col]
+		}
+		tbl.AddRow(cells...)
+	}
+	return render(display.Output{Data: items, Table: tbl})
+}
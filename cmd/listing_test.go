@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+)
+
+func listingServer(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/list/devices":
+			json.NewEncoder(w).Encode([]client.Device{
+				{Name: "Porch Light", Type: "light", Room: "Front", Reachable: false},
+				{Name: "Fan", Type: "fan", Room: "Office", Reachable: true},
+				{Name: "desk lamp", Type: "light", Room: "Office", Reachable: true},
+				{Name: "Sensor", Type: "sensor", Room: "Attic", Reachable: false},
+			})
+		case "/list/groups":
+			json.NewEncoder(w).Encode([]client.Group{
+				{Name: "All", Devices: 10},
+				{Name: "Desk", Room: "Office", Devices: 2},
+				{Name: "Hall", Room: "Hall", Devices: 9},
+			})
+		case "/list/rooms":
+			json.NewEncoder(w).Encode([]client.Room{{Name: "Office"}, {Name: "Attic"}})
+		case "/list/scenes":
+			json.NewEncoder(w).Encode([]client.Scene{{Name: "Morning"}, {Name: "Goodnight"}})
+		}
+	})
+}
+
+func TestListSortFilterColumns(t *testing.T) {
+	listingServer(t)
+	outputFormat = display.FormatTable
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"list", "devices", "--sort", "name", "-o", "csv"},
+			"Device,Type,Room,Status\ndesk lamp,light,Office,ok\nFan,fan,Office,ok\nPorch Light,light,Front,unreachable\nSensor,sensor,Attic,unreachable\n"},
+		{[]string{"list", "devices", "--sort", "room", "--reverse", "--columns", "room,name", "--no-headers", "-o", "tsv"},
+			"Office\tdesk lamp\nOffice\tFan\nFront\tPorch Light\nAttic\tSensor\n"},
+		{[]string{"list", "devices", "--filter", "type=light", "--columns", "name", "-o", "csv"},
+			"Device\nPorch Light\ndesk lamp\n"},
+		{[]string{"list", "devices", "--filter", "type=LIGHT,room=off*", "--field", "name"},
+			"desk lamp\n"},
+		{[]string{"list", "devices", "--unreachable", "--sort", "name", "--field", "name"},
+			"Porch Light\nSensor\n"},
+		{[]string{"list", "devices", "--unreachable", "--filter", "type=fan", "-o", "json"},
+			"[]\n"},
+		{[]string{"list", "groups", "--sort", "devices", "--field", "name"},
+			"Desk\nHall\nAll\n"},
+		{[]string{"list", "groups", "--filter", "room=(global)", "--columns", "name,devices", "--no-headers"},
+			"All | 10\n"},
+		{[]string{"list", "rooms", "--sort", "name", "--no-headers"},
+			"Attic \nOffice\n"},
+		{[]string{"list", "scenes", "--reverse", "-o", "csv", "--no-headers"},
+			"Goodnight\nMorning\n"},
+	}
+	for _, tt := range tests {
+		out := captureStdout(t, func() {
+			if _, err := executeCmd(tt.args...); err != nil {
+				t.Fatalf("%v: unexpected error: %v", tt.args, err)
+			}
+		})
+		if out != tt.want {
+			t.Errorf("%v: expected %q, got %q", tt.args, tt.want, out)
+		}
+	}
+}
+
+func TestListOptionErrors(t *testing.T) {
+	listingServer(t)
+
+	tests := []struct {
+		args   []string
+		errMsg string
+	}{
+		{[]string{"list", "devices", "--sort", "icon"}, `cannot sort by "icon" (use name, type, room or status)`},
+		{[]string{"list", "devices", "--columns", "name,colour"}, `unknown column "colour" (use name, type, room or status)`},
+		{[]string{"list", "devices", "--filter", "light"}, `invalid --filter "light": expected key=value`},
+		{[]string{"list", "devices", "--filter", "colour=red"}, `unknown --filter key "colour" (use name, type, room or status)`},
+		{[]string{"list", "devices", "--filter", "name=["}, `invalid --filter "name=[": syntax error in pattern`},
+		{[]string{"list", "rooms", "--sort", "room"}, `cannot sort by "room" (use name)`},
+		{[]string{"list", "scenes", "--unreachable"}, "unknown flag: --unreachable"},
+	}
+	for _, tt := range tests {
+		if _, err := executeCmd(tt.args...); err == nil || err.Error() != tt.errMsg {
+			t.Errorf("%v: expected %q, got %v", tt.args, tt.errMsg, err)
+		}
+	}
+}
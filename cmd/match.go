@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+)
+
+// confirmAbove is the number of matched targets above which a pattern needs
+// --yes before anything is sent.
+const confirmAbove = 10
+
+// regexPrefix marks a target as a regular expression instead of a glob.
+const regexPrefix = "re:"
+
+// isPattern reports whether target selects devices by pattern. Only "*"
+// turns a target into a glob, so names containing "?" or "[" still work as
+// they are; the rest of the glob syntax is available once a "*" is present.
+func isPattern(target string) bool {
+	return strings.HasPrefix(target, regexPrefix) || strings.Contains(target, "*")
+}
+
+// candidate is a device or group a pattern can expand to.
+type candidate struct {
+	key       string
+	name      string
+	room      string
+	typ       string
+	reachable bool
+	group     bool
+}
+
+// targetPattern matches candidates against a glob or, with the re: prefix, a
+// regular expression. Both ignore case.
+type targetPattern struct {
+	text   string
+	re     *regexp.Regexp
+	groups bool
+}
+
+func parsePattern(text string) (targetPattern, error) {
+	p := targetPattern{text: text, groups: strings.Contains(strings.ToLower(text), "group.")}
+	if expr, ok := strings.CutPrefix(text, regexPrefix); ok {
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return p, fmt.Errorf("invalid pattern %q: %w", text, err)
+		}
+		p.re = re
+		return p, nil
+	}
+	if _, err := path.Match(text, ""); err != nil {
+		return p, fmt.Errorf("invalid pattern %q: %w", text, err)
+	}
+	return p, nil
+}
+
+// match reports whether c matches. Groups are only candidates when the
+// pattern names them with "group.", so "Office/*" does not also pick up the
+// room's groups. A glob without a "/" is matched against the name alone.
+func (p targetPattern) match(c candidate) bool {
+	if c.group != p.groups {
+		return false
+	}
+	if p.re != nil {
+		return p.re.MatchString(c.key)
+	}
+	s := c.key
+	if !strings.Contains(p.text, "/") {
+		s = c.name
+	}
+	ok, _ := path.Match(strings.ToLower(p.text), strings.ToLower(s))
+	return ok
+}
+
+// parseMatch parses --match, a comma-separated list of key=glob pairs that
+// must all hold for a device to be selected.
+func parseMatch(s string) (func(candidate) bool, error) {
+	type field struct {
+		key, value string
+	}
+	var fields []field
+	for _, part := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --match %q: expected key=value", part)
+		}
+		switch key {
+		case "name", "room", "type":
+			if _, err := path.Match(value, ""); err != nil {
+				return nil, fmt.Errorf("invalid --match %q: %w", part, err)
+			}
+		case "reachable":
+			if _, err := strconv.ParseBool(value); err != nil {
+				return nil, fmt.Errorf("invalid --match %q: reachable must be true or false", part)
+			}
+		default:
+			return nil, fmt.Errorf("unknown --match key %q (use name, room, type or reachable)", key)
+		}
+		fields = append(fields, field{key, value})
+	}
+
+	return func(c candidate) bool {
+		if c.group {
+			return false
+		}
+		for _, f := range fields {
+			var s string
+			switch f.key {
+			case "name":
+				s = c.name
+			case "room":
+				s = c.room
+			case "type":
+				s = c.typ
+			case "reachable":
+				want, _ := strconv.ParseBool(f.value)
+				if c.reachable != want {
+					return false
+				}
+				continue
+			}
+			if ok, _ := path.Match(strings.ToLower(f.value), strings.ToLower(s)); !ok {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func listCandidates(ctx context.Context, c *client.Client) ([]candidate, error) {
+	devices, err := c.ListDevicesContext(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	groups, err := c.ListGroupsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var cands []candidate
+	for _, d := range devices {
+		cands = append(cands, candidate{key: deviceKey(d.Room, d.Name), name: d.Name, room: d.Room, typ: d.Type, reachable: d.Reachable})
+	}
+	for _, g := range groups {
+		name := "group." + g.Name
+		cands = append(cands, candidate{key: deviceKey(g.Room, name), name: name, room: g.Room, reachable: true, group: true})
+	}
+	return cands, nil
+}
+
+// expand replaces glob and regex targets, and adds --match, with the devices
+// and groups they select. Plain targets are passed through untouched and
+// without a request to the server.
+func (tf targetFlags) expand(ctx context.Context, targets []string) ([]string, error) {
+	var (
+		patterns  []targetPattern
+		selectors []func(candidate) bool
+	)
+	for _, t := range targets {
+		if isPattern(t) {
+			p, err := parsePattern(t)
+			if err != nil {
+				return nil, err
+			}
+			patterns = append(patterns, p)
+		}
+	}
+	if tf.match != "" {
+		m, err := parseMatch(tf.match)
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, m)
+	}
+	if len(patterns) == 0 && len(selectors) == 0 {
+		return targets, nil
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	if tf.match != "" {
+		// --match can select on reachability, which a cached list may not reflect
+		c = c.Fresh()
+	}
+	cands, err := listCandidates(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var expanded []string
+	add := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			expanded = append(expanded, key)
+		}
+	}
+	selectAll := func(desc string, sel func(candidate) bool) error {
+		n := 0
+		for _, c := range cands {
+			if sel(c) {
+				add(c.key)
+				n++
+			}
+		}
+		if n == 0 {
+			return fmt.Errorf("nothing matches %s", desc)
+		}
+		return nil
+	}
+
+	next := 0
+	for _, t := range targets {
+		if !isPattern(t) {
+			add(t)
+			continue
+		}
+		if err := selectAll(t, patterns[next].match); err != nil {
+			return nil, err
+		}
+		next++
+	}
+	for _, sel := range selectors {
+		if err := selectAll("--match "+tf.match, sel); err != nil {
+			return nil, err
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Matched %d targets:\n", len(expanded))
+	for _, t := range expanded {
+		fmt.Fprintf(os.Stderr, "  %s\n", t)
+	}
+	if len(expanded) > confirmAbove && !tf.yes {
+		return nil, fmt.Errorf("%d targets matched: pass --yes to control more than %d at once", len(expanded), confirmAbove)
+	}
+	return expanded, nil
+}
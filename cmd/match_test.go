@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+)
+
+func TestParsePattern(t *testing.T) {
+	for _, text := range []string{"re:(", "Office/[*"} {
+		if _, err := parsePattern(text); err == nil || !strings.Contains(err.Error(), "invalid pattern") {
+			t.Errorf("%q: expected invalid pattern error, got %v", text, err)
+		}
+	}
+}
+
+func TestTargetPatternMatch(t *testing.T) {
+	lamp := candidate{key: "Office/Desk Lamp", name: "Desk Lamp", room: "Office"}
+	porch := candidate{key: "Front/Porch Light", name: "Porch Light", room: "Front"}
+	group := candidate{key: "Office/group.Lights", name: "group.Lights", room: "Office", group: true}
+	global := candidate{key: "group.All", name: "group.All", group: true}
+
+	tests := []struct {
+		pattern string
+		c       candidate
+		want    bool
+	}{
+		{"Office/*", lamp, true},
+		{"office/*", lamp, true},
+		{"Office/*", group, false},
+		{"*/Porch*", porch, true},
+		{"*/Porch*", lamp, false},
+		{"*Lamp", lamp, true},
+		{"Office/group.*", group, true},
+		{"group.*", global, true},
+		{"group.*", group, true},
+		{"re:^office/desk", lamp, true},
+		{"re:Light$", porch, true},
+		{"re:Light$", lamp, false},
+		{"re:^group.all$", global, true},
+	}
+	for _, tt := range tests {
+		p, err := parsePattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tt.pattern, err)
+		}
+		if got := p.match(tt.c); got != tt.want {
+			t.Errorf("%q on %s: expected %v, got %v", tt.pattern, tt.c.key, tt.want, got)
+		}
+	}
+}
+
+func TestParseMatch(t *testing.T) {
+	light := candidate{key: "Kitchen/Light", name: "Light", room: "Kitchen", typ: "light", reachable: true}
+	fan := candidate{key: "Kitchen/Fan", name: "Fan", room: "Kitchen", typ: "fan"}
+	group := candidate{key: "Kitchen/group.All", name: "group.All", room: "Kitchen", reachable: true, group: true}
+
+	m, err := parseMatch("type=Light, room=kit*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !m(light) || m(fan) || m(group) {
+		t.Error("unexpected type/room match result")
+	}
+
+	m, err = parseMatch("reachable=false,name=F*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m(light) || !m(fan) {
+		t.Error("unexpected reachable/name match result")
+	}
+
+	tests := []struct {
+		match  string
+		errMsg string
+	}{
+		{"light", `invalid --match "light": expected key=value`},
+		{"=light", `invalid --match "=light": expected key=value`},
+		{"colour=red", `unknown --match key "colour" (use name, room, type or reachable)`},
+		{"name=[", `invalid --match "name=[": syntax error in pattern`},
+		{"reachable=maybe", `invalid --match "reachable=maybe": reachable must be true or false`},
+	}
+	for _, tt := range tests {
+		if _, err := parseMatch(tt.match); err == nil || err.Error() != tt.errMsg {
+			t.Errorf("%q: expected %q, got %v", tt.match, tt.errMsg, err)
+		}
+	}
+}
+
+// matchServer lists the given devices and groups and records control
+// requests. fail makes the named list endpoint return an error.
+func matchServer(t *testing.T, devices []client.Device, fail string) func() []string {
+	var reqs requestLog
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == fail {
+			w.WriteHeader(500)
+			return
+		}
+		switch r.URL.Path {
+		case "/list/devices":
+			json.NewEncoder(w).Encode(devices)
+		case "/list/groups":
+			json.NewEncoder(w).Encode([]client.Group{{Name: "All Lights"}, {Name: "Desk", Room: "Office"}})
+		default:
+			reqs.record(r)
+			json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+		}
+	})
+	return func() []string {
+		got := reqs.paths()
+		sort.Strings(got)
+		return got
+	}
+}
+
+var matchDevices = []client.Device{
+	{Name: "Desk Lamp", Type: "light", Room: "Office", Reachable: true},
+	{Name: "Fan", Type: "fan", Room: "Office", Reachable: true},
+	{Name: "Light", Type: "light", Room: "Kitchen", Reachable: true},
+	{Name: "Porch Light", Type: "light", Room: "Front", Reachable: false},
+}
+
+func TestControlGlob(t *testing.T) {
+	paths := matchServer(t, matchDevices, "")
+
+	outputFormat = display.FormatTable
+	captureStdout(t, func() {
+		if _, err := executeCmd("off", "Office/*"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	want := []string{"/off/Office/Desk Lamp", "/off/Office/Fan"}
+	if got := paths(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestControlMatchAndPatterns(t *testing.T) {
+	paths := matchServer(t, matchDevices, "")
+
+	outputFormat = display.FormatTable
+	captureStdout(t, func() {
+		if _, err := executeCmd("brightness", "40", "--targets", "group.*,Hall/Lamp", "-t", "re:porch", "--match", "type=light,room=Kitchen"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	want := []string{"/brightness/40/Front/Porch Light", "/brightness/40/Hall/Lamp", "/brightness/40/Kitchen/Light", "/brightness/40/Office/group.Desk", "/brightness/40/group.All Lights"}
+	if got := paths(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestControlPatternSingleMatch(t *testing.T) {
+	paths := matchServer(t, matchDevices, "")
+
+	outputFormat = display.FormatTable
+	out := captureStdout(t, func() {
+		if _, err := executeCmd("on", "--match", "reachable=false"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	if got := paths(); !reflect.DeepEqual(got, []string{"/on/Front/Porch Light"}) {
+		t.Errorf("unexpected requests: %v", got)
+	}
+	if out != "success\n" {
+		t.Errorf("expected single-target output, got %q", out)
+	}
+}
+
+func TestControlPatternNeedsYes(t *testing.T) {
+	var devices []client.Device
+	for i := 0; i < confirmAbove+1; i++ {
+		devices = append(devices, client.Device{Name: fmt.Sprintf("Light %d", i), Room: "Hall"})
+	}
+	paths := matchServer(t, devices, "")
+
+	_, err := executeCmd("off", "Hall/*")
+	if err == nil || err.Error() != "11 targets matched: pass --yes to control more than 10 at once" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(paths()) != 0 {
+		t.Errorf("expected no requests, got %v", paths())
+	}
+
+	outputFormat = display.FormatTable
+	captureStdout(t, func() {
+		if _, err := executeCmd("off", "Hall/*", "--yes"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	if len(paths()) != confirmAbove+1 {
+		t.Errorf("expected %d requests, got %v", confirmAbove+1, paths())
+	}
+}
+
+func TestControlPatternErrors(t *testing.T) {
+	matchServer(t, matchDevices, "")
+
+	tests := []struct {
+		args   []string
+		errMsg string
+	}{
+		{[]string{"off", "Garage/*"}, "nothing matches Garage/*"},
+		{[]string{"off", "--match", "type=lock"}, "nothing matches --match type=lock"},
+		{[]string{"off", "re:("}, "invalid pattern \"re:(\": error parsing regexp: missing closing ): `(?i)(`"},
+		{[]string{"off", "--match", "bogus"}, `invalid --match "bogus": expected key=value`},
+		{[]string{"scene", "--match", "type=light"}, "unknown flag: --match"},
+	}
+	for _, tt := range tests {
+		if _, err := executeCmd(tt.args...); err == nil || err.Error() != tt.errMsg {
+			t.Errorf("%v: expected %q, got %v", tt.args, tt.errMsg, err)
+		}
+	}
+}
+
+func TestControlPatternListErrors(t *testing.T) {
+	for _, fail := range []string{"/list/devices", "/list/groups"} {
+		paths := matchServer(t, matchDevices, fail)
+		if _, err := executeCmd("off", "Office/*"); err == nil {
+			t.Errorf("%s: expected error, got nil", fail)
+		}
+		if len(paths()) != 0 {
+			t.Errorf("%s: expected no requests, got %v", fail, paths())
+		}
+	}
+}
+
+func TestControlPatternConfigError(t *testing.T) {
+	setupTestEnv(t, nil)
+	t.Setenv("ITSYHOME_PORT", "bogus")
+
+	if _, err := executeCmd("off", "Office/*"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
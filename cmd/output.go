@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+)
+
+// outputFormat is set with --output, or with --json for compatibility.
+var outputFormat = display.FormatTable
+
+// jsonFlag keeps --json working as a shorthand for --output json.
+type jsonFlag struct {
+	format *display.Format
+}
+
+func (j jsonFlag) String() string { return strconv.FormatBool(*j.format == display.FormatJSON) }
+
+func (j jsonFlag) Type() string { return "bool" }
+
+func (j jsonFlag) Set(s string) error {
+	on, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	if on {
+		*j.format = display.FormatJSON
+	} else if *j.format == display.FormatJSON {
+		*j.format = display.FormatTable
+	}
+	return nil
+}
+
+// fieldFlag sets the output format to print one field, e.g. --field name.
+type fieldFlag struct {
+	format *display.Format
+}
+
+func (f fieldFlag) String() string {
+	if f.format.Kind() != display.FormatField {
+		return ""
+	}
+	return strings.TrimPrefix(string(*f.format), string(display.FormatField)+"=")
+}
+
+func (f fieldFlag) Type() string { return "string" }
+
+func (f fieldFlag) Set(s string) error {
+	if s == "" {
+		if f.format.Kind() == display.FormatField {
+			*f.format = display.FormatTable
+		}
+		return nil
+	}
+	return f.format.Set(string(display.FormatField) + "=" + s)
+}
+
+// render prints a command's result in the selected output format.
+func render(out display.Output) error {
+	r, err := display.NewRenderer(outputFormat)
+	if err != nil {
+		return err
+	}
+	return r.Render(os.Stdout, out)
+}
+
+func formatNames() []string {
+	names := make([]string, len(display.Formats))
+	for i, f := range display.Formats {
+		names[i] = string(f)
+	}
+	return names
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+)
+
+func TestOutputFormats(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]client.Group{{Name: "All Lights", Icon: "bulb", Devices: 3}, {Name: "Desk", Room: "Office", Devices: 1}})
+	})
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"json", "[\n  {\n    \"name\": \"All Lights\",\n    \"icon\": \"bulb\",\n    \"devices\": 3\n  },\n  {\n    \"name\": \"Desk\",\n    \"icon\": \"\",\n    \"devices\": 1,\n    \"room\": \"Office\"\n  }\n]\n"},
+		{"ndjson", "{\"name\":\"All Lights\",\"icon\":\"bulb\",\"devices\":3}\n{\"name\":\"Desk\",\"icon\":\"\",\"devices\":1,\"room\":\"Office\"}\n"},
+		{"yaml", "- name: All Lights\n  icon: bulb\n  devices: 3\n- name: Desk\n  icon: \"\"\n  devices: 1\n  room: Office\n"},
+		{"csv", "Group,Room,Icon,Devices\nAll Lights,(global),bulb,3\nDesk,Office,,1\n"},
+		{"tsv", "Group\tRoom\tIcon\tDevices\nAll Lights\t(global)\tbulb\t3\nDesk\tOffice\t\t1\n"},
+		{"markdown", "| Group | Room | Icon | Devices |\n| --- | --- | --- | --- |\n| All Lights | (global) | bulb | 3 |\n| Desk | Office |  | 1 |\n"},
+	}
+	for _, tt := range tests {
+		out := captureStdout(t, func() {
+			if _, err := executeCmd("list", "groups", "-o", tt.format); err != nil {
+				t.Fatalf("%s: unexpected error: %v", tt.format, err)
+			}
+		})
+		if out != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.format, tt.want, out)
+		}
+	}
+}
+
+func TestOutputFormatInvalid(t *testing.T) {
+	setupTestEnv(t, nil)
+
+	_, err := executeCmd("list", "rooms", "--output", "xml")
+	if err == nil || !strings.Contains(err.Error(), `unknown output format "xml"`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestJSONFlag(t *testing.T) {
+	format := display.FormatYAML
+	j := jsonFlag{&format}
+	if j.Type() != "bool" || j.String() != "false" {
+		t.Errorf("unexpected Type/String: %q %q", j.Type(), j.String())
+	}
+	if err := j.Set("maybe"); err == nil {
+		t.Error("expected error for invalid bool")
+	}
+	if j.Set("false"); format != display.FormatYAML {
+		t.Errorf("--json=false should keep another format, got %s", format)
+	}
+	if j.Set("true"); format != display.FormatJSON || j.String() != "true" {
+		t.Errorf("--json should select json, got %s", format)
+	}
+	if j.Set("false"); format != display.FormatTable {
+		t.Errorf("--json=false should go back to table, got %s", format)
+	}
+}
+
+func TestStatusCmdCSV(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/status":
+			json.NewEncoder(w).Encode(map[string]int{"rooms": 2, "devices": 2})
+		case "/list/rooms":
+			json.NewEncoder(w).Encode([]map[string]string{{"name": "Office"}, {"name": "Garage"}})
+		case "/info/Office":
+			json.NewEncoder(w).Encode([]client.DeviceInfo{
+				{Name: "Lamp", Type: "light", Reachable: true, State: map[string]interface{}{"on": true, "brightness": 40}},
+				{Name: "Fan", Type: "fan", Reachable: true, State: map[string]interface{}{"on": false}},
+			})
+		default:
+			w.WriteHeader(500)
+		}
+	})
+
+	var err error
+	out := captureStdout(t, func() {
+		_, err = executeCmd("status", "-o", "csv")
+	})
+	if err == nil || err.Error() != "failed to fetch 1 of 2 rooms" {
+		t.Errorf("expected partial failure error, got %v", err)
+	}
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %q", out)
+	}
+	if lines[0] != "Room,Device,Type,State,Value" || lines[1] != "Office,Lamp,light,on,40%" || lines[2] != "Office,Fan,fan,off," {
+		t.Errorf("unexpected rows: %q", out)
+	}
+	if !strings.HasPrefix(lines[3], "Garage,,,error: ") {
+		t.Errorf("expected error row for Garage, got %q", lines[3])
+	}
+}
+
+func TestWatchCmdUnsupportedOutput(t *testing.T) {
+	setupTestEnv(t, nil)
+	defer resetWatchFlags()
+
+	_, err := executeCmd("watch", "-o", "csv")
+	if err == nil || err.Error() != "watch does not support --output csv (use table, json or ndjson)" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestFieldAndTemplateOutput(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/info/Office/Lamp":
+			json.NewEncoder(w).Encode([]client.DeviceInfo{
+				{Name: "Lamp", Type: "light", Room: "Office", Reachable: true, State: map[string]interface{}{"on": true, "brightness": 80}},
+			})
+		default:
+			json.NewEncoder(w).Encode([]client.Device{{Name: "Lamp", Type: "light", Room: "Office"}, {Name: "Fan", Type: "fan", Room: "Hall"}})
+		}
+	})
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"info", "Office/Lamp", "--field", "state.brightness"}, "80\n"},
+		{[]string{"info", "Office/Lamp", "-o", "jsonpath={.state.brightness}"}, "80"},
+		{[]string{"info", "Office/Lamp", "-o", "go-template={{.Name}}"}, "Lamp"},
+		{[]string{"info", "Office/Lamp", "--json"}, "[\n  {\n    \"name\": \"Lamp\""},
+		{[]string{"info", "Office/Lamp", "-o", "yaml"}, "- name: Lamp"},
+		{[]string{"list", "devices", "--field", "room"}, "Office\nHall\n"},
+		{[]string{"list", "devices", "-o", `go-template={{range .}}{{.Name}} {{end}}`}, "Lamp Fan "},
+		{[]string{"list", "devices", "-o", `jsonpath={range .}{.room}/{.name}{"\n"}{end}`}, "Office/Lamp\nHall/Fan\n"},
+		{[]string{"info", "Office/Lamp", "--field", "name", "--field", ""}, "Property   | Value"},
+	}
+	for _, tt := range tests {
+		out := captureStdout(t, func() {
+			if _, err := executeCmd(tt.args...); err != nil {
+				t.Fatalf("%v: unexpected error: %v", tt.args, err)
+			}
+		})
+		if !strings.HasPrefix(out, tt.want) {
+			t.Errorf("%v: expected %q, got %q", tt.args, tt.want, out)
+		}
+	}
+
+	_, err := executeCmd("info", "Office/Lamp", "--field", "state.hue")
+	if err == nil || err.Error() != `field "state.hue" not found` {
+		t.Errorf("unexpected error: %v", err)
+	}
+	_, err = executeCmd("list", "devices", "-o", "jsonpath={.name")
+	if err == nil || !strings.Contains(err.Error(), "unclosed {") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestFieldFlag(t *testing.T) {
+	format := display.FormatJSON
+	f := fieldFlag{&format}
+	if f.Type() != "string" || f.String() != "" {
+		t.Errorf("unexpected Type/String: %q %q", f.Type(), f.String())
+	}
+	if f.Set(""); format != display.FormatJSON {
+		t.Errorf("an empty --field should keep another format, got %s", format)
+	}
+	if err := f.Set("state.on"); err != nil || f.String() != "state.on" {
+		t.Errorf("Set: got %q, %v", f.String(), err)
+	}
+	if f.Set(""); format != display.FormatTable {
+		t.Errorf("an empty --field should go back to table, got %s", format)
+	}
+	if err := f.Set("a..b"); err == nil {
+		t.Error("expected error for invalid field")
+	}
+}
+
+func TestRenderUnknownFormat(t *testing.T) {
+	defer func() { outputFormat = display.FormatTable }()
+	outputFormat = "xml"
+	if err := render(display.Output{}); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/fuzzy"
+)
+
+// maxSuggestions is how many close matches a "not found" error lists.
+const maxSuggestions = 3
+
+var fuzzyTargets bool
+
+// guardedActions are never sent to a target --fuzzy picked, since acting on
+// the wrong lock or door is worse than failing.
+var guardedActions = map[string]bool{
+	"lock": true, "unlock": true, "open": true, "close": true, "position": true,
+}
+
+// targetNames lists everything the server accepts as a target for action:
+// scene names for the scene command, otherwise rooms, devices, groups and
+// scenes in the forms shown under "Target formats" in the README.
+func targetNames(ctx context.Context, c *client.Client, action string) ([]string, error) {
+	scenes, err := c.ListScenesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if action == "scene" {
+		for _, s := range scenes {
+			names = append(names, s.Name)
+		}
+		return names, nil
+	}
+
+	rooms, err := c.ListRoomsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := c.ListDevicesContext(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	groups, err := c.ListGroupsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rooms {
+		names = append(names, r.Name)
+	}
+	for _, d := range devices {
+		names = append(names, deviceKey(d.Room, d.Name))
+	}
+	for _, g := range groups {
+		names = append(names, deviceKey(g.Room, "group."+g.Name))
+	}
+	for _, s := range scenes {
+		names = append(names, "scene."+s.Name)
+	}
+	return names, nil
+}
+
+// suggest ranks names by how close they are to target, best first. A target
+// without a "/" is also compared with the last part of each name, so "lamp"
+// finds "Office/Lamp".
+func suggest(target string, names []string) []fuzzy.Match {
+	var matches []fuzzy.Match
+	for _, name := range names {
+		if name == target {
+			continue
+		}
+		score, ok := fuzzy.Score(target, name)
+		if i := strings.LastIndex(name, "/"); i >= 0 && !strings.Contains(target, "/") {
+			if s, found := fuzzy.Score(target, name[i+1:]); found && (!ok || s < score) {
+				score, ok = s, true
+			}
+		}
+		if ok {
+			matches = append(matches, fuzzy.Match{Value: name, Score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score < matches[j].Score
+	})
+	return matches
+}
+
+// targetKind classifies a target by its form, as in the "Target formats"
+// table of the README. A bare name is reported as a room, although the
+// server also accepts a device name there.
+func targetKind(name string) string {
+	switch {
+	case strings.HasPrefix(name, "scene."):
+		return "scene"
+	case strings.HasPrefix(name[strings.LastIndex(name, "/")+1:], "group."):
+		return "group"
+	case strings.Contains(name, "/"):
+		return "device"
+	}
+	return "room"
+}
+
+// sameKind returns the matches that are the same kind of target as target.
+// A bare name may be a room or a device, so its matches are only kept when
+// they are all of one kind.
+func sameKind(target string, matches []fuzzy.Match) []fuzzy.Match {
+	want := targetKind(target)
+	if want == "room" {
+		for _, m := range matches {
+			if targetKind(m.Value) != targetKind(matches[0].Value) {
+				return nil
+			}
+		}
+		return matches
+	}
+	var kept []fuzzy.Match
+	for _, m := range matches {
+		if targetKind(m.Value) == want {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// resolveTarget is the client's TargetResolver. With --fuzzy it retries with
+// the closest target of the same kind when exactly one is closest, except
+// for lock and door actions; otherwise it adds the closest targets to the
+// "not found" error.
+func resolveTarget(ctx context.Context, c *client.Client, action, target string, err error) (string, error) {
+	names, lerr := targetNames(ctx, c, action)
+	if lerr != nil {
+		return "", err
+	}
+	matches := suggest(target, names)
+	if len(matches) == 0 {
+		return "", err
+	}
+
+	// Scene names are all bare, so they are all one kind already
+	if picks := sameKind(target, matches); fuzzyTargets && !guardedActions[action] && len(picks) > 0 &&
+		(len(picks) == 1 || picks[0].Score < picks[1].Score) {
+		fmt.Fprintf(os.Stderr, "Using %q for %q\n", picks[0].Value, target)
+		return picks[0].Value, nil
+	}
+
+	if len(matches) > maxSuggestions {
+		matches = matches[:maxSuggestions]
+	}
+	quoted := make([]string, len(matches))
+	for i, m := range matches {
+		quoted[i] = strconv.Quote(m.Value)
+	}
+	list := quoted[0]
+	if n := len(quoted); n > 1 {
+		list = strings.Join(quoted[:n-1], ", ") + " or " + quoted[n-1]
+	}
+	return "", fmt.Errorf("%w (did you mean %s?)", err, list)
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+	"github.com/nickustinov/itsyhome-cli/internal/fuzzy"
+)
+
+func TestSuggest(t *testing.T) {
+	names := []string{"Office", "Office/Lamp", "Office/Desk Lamp", "Kitchen/Light", "group.All Lights", "scene.Goodnight"}
+
+	got := suggest("lamp", names)
+	want := []fuzzy.Match{{Value: "Office/Lamp", Score: 0}, {Value: "Office/Desk Lamp", Score: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	got = suggest("Ofice/Lamp", names)
+	if len(got) == 0 || got[0].Value != "Office/Lamp" {
+		t.Errorf("expected Office/Lamp first, got %+v", got)
+	}
+
+	if got := suggest("Office", names); len(got) != 2 || got[0].Value == "Office" {
+		t.Errorf("expected the target itself to be skipped, got %+v", got)
+	}
+	if got := suggest("Garage", names); len(got) != 0 {
+		t.Errorf("expected no matches, got %+v", got)
+	}
+}
+
+// resolveServer knows the rooms, devices, groups and scenes below. Control
+// and info requests for anything else answer "device not found". fail makes
+// the given path return an error.
+func resolveServer(t *testing.T, fail string) func() []string {
+	var reqs requestLog
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == fail {
+			w.WriteHeader(500)
+			return
+		}
+		switch r.URL.Path {
+		case "/list/rooms":
+			json.NewEncoder(w).Encode([]client.Room{{Name: "Office"}, {Name: "Kitchen"}})
+		case "/list/devices":
+			json.NewEncoder(w).Encode([]client.Device{
+				{Name: "Lamp", Room: "Office"}, {Name: "Desk Lamp", Room: "Office"},
+				{Name: "Lamp 1", Room: "Hall"}, {Name: "Lamp 2", Room: "Hall"}, {Name: "Lamp 3", Room: "Hall"},
+				{Name: "Light", Room: "Kitchen"},
+			})
+		case "/list/groups":
+			json.NewEncoder(w).Encode([]client.Group{{Name: "All Lights"}})
+		case "/list/scenes":
+			json.NewEncoder(w).Encode([]client.Scene{{Name: "Goodnight"}})
+		case "/on/Office/Lamp", "/scene/Goodnight", "/info/Kitchen/Light":
+			reqs.record(r)
+			if strings.HasPrefix(r.URL.Path, "/info/") {
+				json.NewEncoder(w).Encode(client.DeviceInfo{Name: "Light", Room: "Kitchen"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+		default:
+			json.NewEncoder(w).Encode(map[string]string{"status": "error", "message": "device not found"})
+		}
+	})
+	return reqs.paths
+}
+
+func TestNotFoundSuggestions(t *testing.T) {
+	resolveServer(t, "")
+
+	tests := []struct {
+		args   []string
+		errMsg string
+	}{
+		{[]string{"on", "Ofice/Lamp"}, `device not found (did you mean "Office/Lamp"?)`},
+		{[]string{"on", "Lamp"}, `device not found (did you mean "Office/Lamp", "Office/Desk Lamp" or "Hall/Lamp 1"?)`},
+		{[]string{"on", "--fuzzy", "Hall/Lamp"}, `device not found (did you mean "Hall/Lamp 1", "Hall/Lamp 2" or "Hall/Lamp 3"?)`},
+		{[]string{"scene", "Goodnite"}, `device not found (did you mean "Goodnight"?)`},
+		{[]string{"info", "Kitchn/Lihgt"}, `device not found (did you mean "Kitchen/Light"?)`},
+		{[]string{"on", "Garage"}, "device not found"},
+	}
+	for _, tt := range tests {
+		_, err := executeCmd(tt.args...)
+		if err == nil || err.Error() != tt.errMsg {
+			t.Errorf("%v: expected %q, got %v", tt.args, tt.errMsg, err)
+		}
+	}
+}
+
+func TestFuzzyRetriesBestMatch(t *testing.T) {
+	paths := resolveServer(t, "")
+
+	outputFormat = display.FormatTable
+	out := captureStdout(t, func() {
+		for _, args := range [][]string{{"on", "--fuzzy", "ofice/lamp"}, {"scene", "--fuzzy", "goodnite"}, {"info", "--fuzzy", "Kitchn/Lihgt"}} {
+			if _, err := executeCmd(args...); err != nil {
+				t.Fatalf("%v: unexpected error: %v", args, err)
+			}
+		}
+	})
+	fuzzyTargets = false
+
+	want := []string{"/on/Office/Lamp", "/scene/Goodnight", "/info/Kitchen/Light"}
+	if got := paths(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if !strings.HasPrefix(out, "success\nsuccess\n") {
+		t.Errorf("unexpected output: %q", out)
+	}
+}
+
+func TestSuggestionsListErrors(t *testing.T) {
+	for _, fail := range []string{"/list/scenes", "/list/rooms", "/list/devices", "/list/groups"} {
+		resolveServer(t, fail)
+		_, err := executeCmd("on", "Ofice/Lamp")
+		if err == nil || err.Error() != "device not found" {
+			t.Errorf("%s: expected the plain error, got %v", fail, err)
+		}
+	}
+}
+
+func TestTargetKinds(t *testing.T) {
+	for name, want := range map[string]string{
+		"Office": "room", "Office/Lamp": "device", "group.All": "group",
+		"Office/group.Desk": "group", "scene.Goodnight": "scene",
+	} {
+		if got := targetKind(name); got != want {
+			t.Errorf("%s: expected %s, got %s", name, want, got)
+		}
+	}
+
+	mixed := []fuzzy.Match{{Value: "Office/Lamp"}, {Value: "Office", Score: 1}, {Value: "group.All Lights", Score: 2}}
+	if got := sameKind("Ofice/Lamp", mixed); len(got) != 1 || got[0].Value != "Office/Lamp" {
+		t.Errorf("expected only the device, got %+v", got)
+	}
+	if got := sameKind("group.All", mixed); len(got) != 1 || got[0].Value != "group.All Lights" {
+		t.Errorf("expected only the group, got %+v", got)
+	}
+	if got := sameKind("lamp", mixed); got != nil {
+		t.Errorf("expected no picks for a bare name with mixed matches, got %+v", got)
+	}
+	devices := mixed[:1]
+	if got := sameKind("lamp", devices); !reflect.DeepEqual(got, devices) {
+		t.Errorf("expected the devices, got %+v", got)
+	}
+}
+
+func TestFuzzyNeverPicksForGuardedActions(t *testing.T) {
+	paths := resolveServer(t, "")
+	defer func() { fuzzyTargets = false }()
+
+	_, err := executeCmd("unlock", "--fuzzy", "ofice/lamp")
+	if err == nil || err.Error() != `device not found (did you mean "Office/Lamp"?)` {
+		t.Errorf("expected suggestions instead of a pick, got %v", err)
+	}
+	if got := paths(); len(got) != 0 {
+		t.Errorf("expected no request to a picked target, got %v", got)
+	}
+}
+
+func TestPollingSkipsResolver(t *testing.T) {
+	var lists int32
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/list/") {
+			atomic.AddInt32(&lists, 1)
+			json.NewEncoder(w).Encode([]client.Room{{Name: "Office"}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "error", "message": "device not found"})
+	})
+	defer resetWaitFlags()
+	defer resetWatchFlags()
+
+	captureStderr(t, func() {
+		executeCmd("wait", "Ofice/Lamp", "on=true", "-n", "1ms", "--max-wait", "20ms")
+		executeCmd("watch", "Ofice/Lamp", "-n", "1ms", "--count", "3")
+	})
+	if n := atomic.LoadInt32(&lists); n != 0 {
+		t.Errorf("expected no list requests while polling, got %d", n)
+	}
+}
@@ -1,19 +1,39 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
 
+	"github.com/nickustinov/itsyhome-cli/internal/cache"
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/config"
 	"github.com/spf13/cobra"
 )
 
 var Version = "dev"
 
 var (
-	jsonOutput bool
-	osExit     = os.Exit
+	timeout     time.Duration
+	profileName string
+	connFlags   config.Config
+	osExit      = os.Exit
+
+	noCache      bool
+	refreshCache bool
+	offline      bool
+	cacheTTL     time.Duration
+	userCacheDir = os.UserCacheDir
 )
 
+// defaultCacheTTL is how long list responses are reused. Rooms, scenes and
+// groups rarely change; use --refresh after editing them in the Home app.
+const defaultCacheTTL = 5 * time.Minute
+
 var rootCmd = &cobra.Command{
 	Use:     "itsyhome",
 	Short:   "Control your HomeKit devices via Itsyhome",
@@ -21,13 +41,94 @@ var rootCmd = &cobra.Command{
 	Version: Version,
 }
 
+// exitError makes Execute exit with a specific status instead of 1.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	patchBashCompletion(rootCmd)
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			osExit(exitErr.code)
+			return
+		}
 		osExit(1)
 	}
 }
 
+func resolveConfig() (config.Resolved, error) {
+	return config.Resolve(profileName, connFlags)
+}
+
+func loadConfig() (config.Config, error) {
+	r, err := resolveConfig()
+	if err != nil {
+		return config.Config{}, err
+	}
+	return r.Config, nil
+}
+
+func newClient() (*client.Client, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	opts := []client.Option{client.WithTimeout(timeout), client.WithTargetResolver(resolveTarget)}
+	if actionObserver != nil {
+		opts = append(opts, client.WithActionObserver(actionObserver))
+	}
+	if offline {
+		if noCache || refreshCache {
+			return nil, fmt.Errorf("--offline cannot be used with --no-cache or --refresh")
+		}
+		opts = append(opts, client.WithOffline())
+	}
+	if !noCache {
+		// Without a cache directory everything is fetched
+		if store, err := listCache(); err == nil {
+			ttl := cacheTTL
+			if refreshCache {
+				ttl = 0
+			}
+			opts = append(opts, client.WithListCache(store, ttl), client.WithStaleNotice(staleNotice))
+		}
+	}
+	return client.New(cfg, opts...)
+}
+
+func staleNotice(path string, age time.Duration) {
+	fmt.Fprintf(os.Stderr, "Warning: server unreachable, using %s cached %s ago\n", path, age.Round(time.Second))
+}
+
+func listCache() (*cache.Store, error) {
+	dir, err := userCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return cache.New(filepath.Join(dir, "itsyhome")), nil
+}
+
 func init() {
-	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Output in JSON format")
+	rootCmd.PersistentFlags().VarP(&outputFormat, "output", "o", "Output format: table, json, ndjson, yaml, csv, tsv, markdown, go-template=TEMPLATE or jsonpath=EXPR")
+	rootCmd.PersistentFlags().Var(jsonFlag{&outputFormat}, "json", "Output in JSON format (same as --output json)")
+	rootCmd.PersistentFlags().Lookup("json").NoOptDefVal = "true"
+	rootCmd.PersistentFlags().Var(fieldFlag{&outputFormat}, "field", "Print only this field, one line per result, e.g. state.brightness")
+	rootCmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(formatNames(), cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Connection profile to use (default $"+config.ProfileEnv+" or the active profile)")
+	addConnectionFlags(rootCmd.PersistentFlags(), &connFlags)
+	rootCmd.PersistentFlags().BoolVar(&fuzzyTargets, "fuzzy", false, "Use the closest match when a target is not found")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", client.DefaultTimeout, "Request timeout (0 to disable)")
+	rootCmd.PersistentFlags().DurationVar(&cacheTTL, "cache-ttl", defaultCacheTTL, "How long to reuse cached rooms, devices, scenes and groups")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Do not read or write the list cache")
+	rootCmd.PersistentFlags().BoolVar(&refreshCache, "refresh", false, "Fetch lists from the server and update the cache")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "Answer lists from the cache without contacting the server")
 }
@@ -0,0 +1,332 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	runContinueOnError bool
+	openDevNull        = func() (*os.File, error) { return os.OpenFile(os.DevNull, os.O_WRONLY, 0) }
+)
+
+// actionObserver, when set, is passed to every client created by newClient.
+// The run command uses it to collect each step's responses.
+var actionObserver func(path string, resp *client.ActionResponse, err error)
+
+// scriptMu serializes runScript. A script swaps process-wide state for its
+// whole duration (os.Stdout, actionObserver, rootCmd's flags and silencing),
+// so two scripts in the same process must not overlap.
+var scriptMu sync.Mutex
+
+var runCmd = &cobra.Command{
+	Use:   "run <file|->",
+	Short: "Run commands from a file or stdin, one per line",
+	Long: "Run itsyhome commands from a file (or stdin with -), one per line, e.g.\n\n" +
+		"  on Kitchen/Light\n" +
+		"  brightness 40 \"Office/Desk Lamp\"\n" +
+		"  sleep 2s\n" +
+		"  wait Garage/Door closed=true\n\n" +
+		"Lines starting with # are comments. Arguments can be quoted as in a shell.\n" +
+		"The run stops at the first failing line unless --continue-on-error is given.\n" +
+		"With --json or another --output format, a summary of every step and its\n" +
+		"responses is printed instead.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var r io.Reader = cmd.InOrStdin()
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		lines, err := parseScript(r)
+		if err != nil {
+			return err
+		}
+		return runScript(cmd.Context(), lines)
+	},
+}
+
+type scriptLine struct {
+	line int
+	args []string
+}
+
+type runStep struct {
+	Line      int                     `json:"line"`
+	Command   string                  `json:"command"`
+	Status    string                  `json:"status"`
+	Error     string                  `json:"error,omitempty"`
+	Responses []client.ActionResponse `json:"responses,omitempty"`
+}
+
+type runSummary struct {
+	Steps     []runStep `json:"steps"`
+	Succeeded int       `json:"succeeded"`
+	Failed    int       `json:"failed"`
+	Skipped   int       `json:"skipped"`
+}
+
+// parseScript splits a script into commands, reporting syntax errors with
+// their line number before anything is run.
+func parseScript(r io.Reader) ([]scriptLine, error) {
+	var lines []scriptLine
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		args, err := splitLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		lines = append(lines, scriptLine{line: n, args: args})
+	}
+	return lines, sc.Err()
+}
+
+// splitLine splits a line into arguments like a shell would: whitespace
+// separates arguments, quotes group them and backslash escapes a character.
+func splitLine(line string) ([]string, error) {
+	var (
+		args    []string
+		cur     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+func runScript(ctx context.Context, lines []scriptLine) error {
+	scriptMu.Lock()
+	defer scriptMu.Unlock()
+
+	quiet := outputFormat != display.FormatTable
+	restoreFlags := snapshotFlags(rootCmd)
+	silenceErrors, silenceUsage := rootCmd.SilenceErrors, rootCmd.SilenceUsage
+	rootCmd.SilenceErrors, rootCmd.SilenceUsage = true, true
+
+	summary := runSummary{Steps: make([]runStep, len(lines))}
+	var (
+		mu      sync.Mutex
+		current *runStep
+	)
+	actionObserver = func(path string, resp *client.ActionResponse, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			current.Responses = append(current.Responses, client.ActionResponse{Status: "error", Message: err.Error()})
+			return
+		}
+		current.Responses = append(current.Responses, *resp)
+	}
+	defer func() {
+		actionObserver = nil
+		rootCmd.SilenceErrors, rootCmd.SilenceUsage = silenceErrors, silenceUsage
+		rootCmd.SetArgs(nil)
+	}()
+
+	stop := false
+	for i, l := range lines {
+		step := &summary.Steps[i]
+		step.Line = l.line
+		step.Command = strings.Join(l.args, " ")
+		if stop || ctx.Err() != nil {
+			step.Status = "skipped"
+			summary.Skipped++
+			continue
+		}
+
+		mu.Lock()
+		current = step
+		mu.Unlock()
+		err := runScriptLine(ctx, l.args, quiet)
+		restoreFlags()
+
+		if err != nil {
+			step.Status = "error"
+			step.Error = err.Error()
+			summary.Failed++
+			fmt.Fprintf(os.Stderr, "line %d: %s: %s\n", l.line, step.Command, err)
+			stop = !runContinueOnError
+			continue
+		}
+		step.Status = "ok"
+		summary.Succeeded++
+	}
+
+	var err error
+	if quiet {
+		tbl := display.NewTable("Line", "Command", "Status")
+		for _, st := range summary.Steps {
+			status := st.Status
+			if st.Error != "" {
+				status = "error: " + st.Error
+			}
+			tbl.AddRow(strconv.Itoa(st.Line), st.Command, status)
+		}
+		err = render(display.Output{Data: summary, Table: tbl})
+	}
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d of %d steps failed", summary.Failed, len(lines))
+	}
+	if summary.Skipped > 0 {
+		return fmt.Errorf("interrupted: %d of %d steps not run", summary.Skipped, len(lines))
+	}
+	return err
+}
+
+// runScriptLine runs one script command through the root command. With
+// quiet set, command output is discarded so only the summary is printed.
+//
+// Re-entering rootCmd.ExecuteContext from inside the run command is safe
+// because every command runs synchronously on this goroutine: by the time
+// it returns, nothing it started still writes to os.Stdout or reads its
+// flags. runScript holds scriptMu and resets the flags after each line, and
+// run itself is refused here, so there is never more than one level of
+// nesting.
+func runScriptLine(ctx context.Context, args []string, quiet bool) error {
+	switch args[0] {
+	case "sleep":
+		return scriptSleep(ctx, args[1:])
+	case "run":
+		return fmt.Errorf("run cannot be used inside a script")
+	}
+
+	if quiet {
+		devNull, err := openDevNull()
+		if err != nil {
+			return err
+		}
+		stdout := os.Stdout
+		os.Stdout = devNull
+		defer func() {
+			os.Stdout = stdout
+			devNull.Close()
+		}()
+	}
+	rootCmd.SetArgs(args)
+	return rootCmd.ExecuteContext(ctx)
+}
+
+// scriptSleep pauses for a duration such as "2s", or a plain number of seconds.
+func scriptSleep(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: sleep <duration>")
+	}
+	d, err := time.ParseDuration(args[0])
+	if err != nil {
+		secs, ferr := strconv.ParseFloat(args[0], 64)
+		if ferr != nil {
+			return fmt.Errorf("invalid duration %q", args[0])
+		}
+		d = time.Duration(secs * float64(time.Second))
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// snapshotFlags records every flag in the command tree and returns a
+// function that puts them back, so flags given on one script line do not
+// carry over to the next.
+func snapshotFlags(root *cobra.Command) func() {
+	type saved struct {
+		flag    *pflag.Flag
+		value   string
+		slice   []string
+		changed bool
+	}
+	var all []saved
+	visit := func(f *pflag.Flag) {
+		s := saved{flag: f, value: f.Value.String(), changed: f.Changed}
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			s.slice = sv.GetSlice()
+		}
+		all = append(all, s)
+	}
+	var walk func(*cobra.Command)
+	walk = func(c *cobra.Command) {
+		c.Flags().VisitAll(visit)
+		c.PersistentFlags().VisitAll(visit)
+		for _, sub := range c.Commands() {
+			walk(sub)
+		}
+	}
+	walk(root)
+
+	return func() {
+		for _, s := range all {
+			if sv, ok := s.flag.Value.(pflag.SliceValue); ok {
+				sv.Replace(s.slice)
+			} else {
+				s.flag.Value.Set(s.value)
+			}
+			s.flag.Changed = s.changed
+		}
+	}
+}
+
+func init() {
+	runCmd.Flags().BoolVar(&runContinueOnError, "continue-on-error", false, "Keep going after a failing line")
+	rootCmd.AddCommand(runCmd)
+}
@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+)
+
+func TestSplitLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"on Kitchen/Light", []string{"on", "Kitchen/Light"}},
+		{`brightness 40   "Office/Desk Lamp"`, []string{"brightness", "40", "Office/Desk Lamp"}},
+		{`color '#FF6600' Kids\ Room/Lamp`, []string{"color", "#FF6600", "Kids Room/Lamp"}},
+		{`scene "Good \"night\""`, []string{"scene", `Good "night"`}},
+		{`on ''`, []string{"on", ""}},
+	}
+	for _, tt := range tests {
+		got, err := splitLine(tt.line)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: expected %q, got %q", tt.line, tt.want, got)
+		}
+	}
+
+	if _, err := splitLine(`on "Kitchen`); err == nil || err.Error() != "unterminated \" quote" {
+		t.Errorf("expected unterminated quote error, got %v", err)
+	}
+	if _, err := splitLine(`on Kitchen\`); err == nil || err.Error() != "trailing backslash" {
+		t.Errorf("expected trailing backslash error, got %v", err)
+	}
+}
+
+func TestParseScript(t *testing.T) {
+	script := "# lights\n\non Kitchen/Light\n   \n  sleep 1s  \n"
+	lines, err := parseScript(strings.NewReader(script))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []scriptLine{{line: 3, args: []string{"on", "Kitchen/Light"}}, {line: 5, args: []string{"sleep", "1s"}}}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("expected %+v, got %+v", want, lines)
+	}
+
+	_, err = parseScript(strings.NewReader("on Lamp\noff 'Lamp\n"))
+	if err == nil || err.Error() != "line 2: unterminated ' quote" {
+		t.Errorf("expected line-numbered error, got %v", err)
+	}
+
+	_, err = parseScript(errorReader{})
+	if err == nil {
+		t.Error("expected read error")
+	}
+}
+
+type errorReader struct{}
+
+func (errorReader) Read([]byte) (int, error) { return 0, errors.New("read error") }
+
+// runServer accepts any control request except for targets named Broken.
+// Listing fails, so a "not found" error has no suggestions.
+func runServer(t *testing.T) func() []string {
+	var reqs requestLog
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/list/") {
+			w.WriteHeader(500)
+			return
+		}
+		reqs.record(r)
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/info/"):
+			json.NewEncoder(w).Encode(client.DeviceInfo{Name: "Door", Reachable: true, State: map[string]interface{}{"closed": true}})
+		case strings.HasSuffix(r.URL.Path, "/Broken"):
+			json.NewEncoder(w).Encode(map[string]string{"status": "error", "message": "device not found"})
+		default:
+			json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+		}
+	})
+	return reqs.paths
+}
+
+func writeScript(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "script.txt")
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunCmdJSONSummary(t *testing.T) {
+	runServer(t)
+	path := writeScript(t, `on Kitchen/Light
+brightness 40 "Office/Desk Lamp"
+sleep 1ms
+wait Garage/Door closed=true
+scene Goodnight
+`)
+
+	outputFormat = display.FormatTable
+	defer func() { outputFormat = display.FormatTable }()
+	out := captureStdout(t, func() {
+		if _, err := executeCmd("run", "--json", path); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	var summary runSummary
+	if err := json.Unmarshal([]byte(out), &summary); err != nil {
+		t.Fatalf("expected only the JSON summary, got %q: %v", out, err)
+	}
+	if summary.Succeeded != 5 || summary.Failed != 0 || len(summary.Steps) != 5 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if s := summary.Steps[1]; s.Line != 2 || s.Command != "brightness 40 Office/Desk Lamp" || len(s.Responses) != 1 || s.Responses[0].Status != "success" {
+		t.Errorf("unexpected step: %+v", s)
+	}
+	if s := summary.Steps[3]; s.Status != "ok" || len(s.Responses) != 0 {
+		t.Errorf("unexpected wait step: %+v", s)
+	}
+	if actionObserver != nil {
+		t.Error("expected the action observer to be cleared")
+	}
+}
+
+func TestRunCmdStopsAtFirstError(t *testing.T) {
+	paths := runServer(t)
+	path := writeScript(t, "on Lamp\non Broken\noff Lamp\n")
+
+	outputFormat = display.FormatTable
+	var err error
+	captureStdout(t, func() {
+		_, err = executeCmd("run", path)
+	})
+	if err == nil || err.Error() != "1 of 3 steps failed" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(paths(), " "); got != "/on/Lamp /on/Broken" {
+		t.Errorf("expected the run to stop after line 2, got %s", got)
+	}
+}
+
+func TestRunCmdContinueOnError(t *testing.T) {
+	paths := runServer(t)
+	path := writeScript(t, "on Broken\nbogus\noff Lamp\n")
+
+	outputFormat = display.FormatTable
+	var err error
+	out := captureStdout(t, func() {
+		_, err = executeCmd("run", "--continue-on-error", "--json", path)
+	})
+	if err == nil || err.Error() != "2 of 3 steps failed" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(paths(), " "); got != "/on/Broken /off/Lamp" {
+		t.Errorf("unexpected requests: %s", got)
+	}
+	var summary runSummary
+	json.Unmarshal([]byte(out), &summary)
+	if s := summary.Steps[0]; s.Status != "error" || s.Error != "device not found" || s.Responses[0].Message != "device not found" {
+		t.Errorf("unexpected step: %+v", s)
+	}
+	if s := summary.Steps[1]; s.Line != 2 || !strings.Contains(s.Error, `unknown command "bogus"`) {
+		t.Errorf("unexpected step: %+v", s)
+	}
+	outputFormat = display.FormatTable
+}
+
+func TestRunCmdFlagsDoNotLeak(t *testing.T) {
+	paths := runServer(t)
+	path := writeScript(t, "brightness --force 150 Lamp\nbrightness 150 Lamp\n")
+
+	outputFormat = display.FormatTable
+	var err error
+	captureStdout(t, func() {
+		_, err = executeCmd("run", path)
+	})
+	if err == nil {
+		t.Fatal("expected the second line to fail validation")
+	}
+	if got := strings.Join(paths(), " "); got != "/brightness/150/Lamp" {
+		t.Errorf("unexpected requests: %s", got)
+	}
+}
+
+func TestRunCmdStdin(t *testing.T) {
+	paths := runServer(t)
+	rootCmd.SetIn(strings.NewReader("toggle Lamp\n"))
+	defer rootCmd.SetIn(nil)
+
+	outputFormat = display.FormatTable
+	captureStdout(t, func() {
+		if _, err := executeCmd("run", "-"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	if got := strings.Join(paths(), " "); got != "/toggle/Lamp" {
+		t.Errorf("unexpected requests: %s", got)
+	}
+}
+
+func TestRunCmdErrors(t *testing.T) {
+	runServer(t)
+
+	if _, err := executeCmd("run", filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("expected error for missing file")
+	}
+	if _, err := executeCmd("run", writeScript(t, "on 'Lamp\n")); err == nil || err.Error() != "line 1: unterminated ' quote" {
+		t.Errorf("expected syntax error, got %v", err)
+	}
+
+	tests := []struct {
+		line   string
+		errMsg string
+	}{
+		{"run other.txt", "run cannot be used inside a script"},
+		{"sleep", "usage: sleep <duration>"},
+		{"sleep soon", `invalid duration "soon"`},
+	}
+	for _, tt := range tests {
+		lines, _ := parseScript(strings.NewReader(tt.line))
+		err := runScriptLine(context.Background(), lines[0].args, false)
+		if err == nil || err.Error() != tt.errMsg {
+			t.Errorf("%q: expected %q, got %v", tt.line, tt.errMsg, err)
+		}
+	}
+	orig := openDevNull
+	openDevNull = func() (*os.File, error) { return nil, errors.New("no /dev/null") }
+	defer func() { openDevNull = orig }()
+	if err := runScriptLine(context.Background(), []string{"on", "Lamp"}, true); err == nil || err.Error() != "no /dev/null" {
+		t.Errorf("expected devnull error, got %v", err)
+	}
+
+	if err := scriptSleep(context.Background(), []string{"0.01"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRunCmdInterrupted(t *testing.T) {
+	paths := runServer(t)
+	path := writeScript(t, "on Lamp\nsleep 1h\noff Lamp\n")
+
+	outputFormat = display.FormatTable
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	var err error
+	captureStdout(t, func() {
+		_, err = executeCmdContext(ctx, "run", "--continue-on-error", path)
+	})
+	if err == nil || err.Error() != "1 of 3 steps failed" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(paths(), " "); got != "/on/Lamp" {
+		t.Errorf("unexpected requests: %s", got)
+	}
+
+	lines, _ := parseScript(strings.NewReader("on Lamp\n"))
+	if err := runScript(ctx, lines); err == nil || err.Error() != "interrupted: 1 of 1 steps not run" {
+		t.Errorf("expected interrupted error, got %v", err)
+	}
+}
+
+func TestRunScriptSerialized(t *testing.T) {
+	scriptMu.Lock()
+	done := make(chan error)
+	go func() {
+		lines, _ := parseScript(strings.NewReader("sleep 0\n"))
+		done <- runScript(context.Background(), lines)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("runScript should wait for the running script")
+	case <-time.After(20 * time.Millisecond):
+	}
+	scriptMu.Unlock()
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
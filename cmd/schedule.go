@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+	"github.com/spf13/cobra"
+)
+
+type schedule struct {
+	after time.Duration
+	hold  time.Duration
+}
+
+func addScheduleFlags(cmd *cobra.Command, s *schedule) {
+	cmd.Flags().DurationVar(&s.after, "after", 0, "Wait this long before running, e.g. 10m")
+	cmd.Flags().DurationVar(&s.hold, "for", 0, "Restore the previous state after this long, e.g. 15m")
+}
+
+type restoreStep struct {
+	device string
+	action string
+	value  string
+	// off turns the device off again after the value is restored, since
+	// setting a value or color turns a light on.
+	off bool
+}
+
+// restoreSteps works out how to put each device back into its captured
+// state after action. Devices that do not report the relevant property are
+// left alone.
+func restoreSteps(action string, infos []client.DeviceInfo) []restoreStep {
+	var steps []restoreStep
+	for _, info := range infos {
+		device := deviceKey(info.Room, info.Name)
+		switch action {
+		case "on", "off", "toggle":
+			if on, ok := info.State["on"].(bool); ok {
+				st := restoreStep{device: device, action: "off"}
+				if on {
+					st.action = "on"
+				}
+				steps = append(steps, st)
+			}
+		case "lock", "unlock":
+			if locked, ok := info.State["locked"].(bool); ok {
+				st := restoreStep{device: device, action: "unlock"}
+				if locked {
+					st.action = "lock"
+				}
+				steps = append(steps, st)
+			}
+		case "color":
+			if c, ok := stateColor(info); ok {
+				steps = append(steps, restoreStep{device: device, action: "color", value: c.Hex(), off: wasOff(info)})
+			}
+		default:
+			// Value commands and open/close, which move the position
+			key, restoreAction := "position", "position"
+			if r, ok := valueRanges[action]; ok {
+				key, restoreAction = r.stateKey, action
+			}
+			if v, ok := info.State[key]; ok {
+				value := strconv.Itoa(int(math.Round(toFloat(v))))
+				steps = append(steps, restoreStep{device: device, action: restoreAction, value: value, off: wasOff(info)})
+			}
+		}
+	}
+	return steps
+}
+
+// wasOff reports whether the device said it was off.
+func wasOff(info client.DeviceInfo) bool {
+	on, ok := info.State["on"].(bool)
+	return ok && !on
+}
+
+// runScheduled runs a control command after s.after and, with s.hold set,
+// captures the state of the targets first and restores it once s.hold has
+// passed. Ctrl-C during the hold restores straight away.
+func runScheduled(ctx context.Context, s schedule, action string, targets []string, run func(context.Context) error) error {
+	if s.after < 0 || s.hold < 0 {
+		return fmt.Errorf("--after and --for must not be negative")
+	}
+	if s.hold > 0 && action == "scene" {
+		return fmt.Errorf("--for is not supported for scenes")
+	}
+
+	label := strings.Join(targets, ", ")
+	if s.after > 0 && !countdown(ctx, s.after, fmt.Sprintf("Running %s %s in", action, label)) {
+		return fmt.Errorf("cancelled before running %s %s", action, label)
+	}
+	if s.hold == 0 {
+		return run(ctx)
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	var steps []restoreStep
+	for _, target := range targets {
+		infos, err := c.GetInfoContext(ctx, target)
+		if err != nil {
+			return err
+		}
+		steps = append(steps, restoreSteps(action, infos)...)
+	}
+	if len(steps) == 0 {
+		return fmt.Errorf("cannot use --for: no device in %s reports a state to restore", label)
+	}
+
+	if err := run(ctx); err != nil {
+		return err
+	}
+
+	countdown(ctx, s.hold, fmt.Sprintf("Restoring %s in", label))
+	return restoreState(context.WithoutCancel(ctx), c, steps)
+}
+
+func restoreState(ctx context.Context, c *client.Client, steps []restoreStep) error {
+	failed := 0
+	for _, st := range steps {
+		var err error
+		if st.value == "" {
+			_, err = c.Control(ctx, st.action, st.device)
+		} else {
+			_, err = c.ControlValue(ctx, st.action, st.value, st.device)
+		}
+		if err == nil && st.off {
+			_, err = c.Control(ctx, "off", st.device)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: restoring %s: %s\n", st.device, err)
+			failed++
+			continue
+		}
+		if outputFormat == display.FormatTable {
+			fmt.Printf("restored %s\n", st.device)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to restore %d of %d devices", failed, len(steps))
+	}
+	return nil
+}
+
+// countdown waits for d while showing the time left on stderr, when stderr
+// is a terminal. It returns false if ctx is cancelled first.
+func countdown(ctx context.Context, d time.Duration, label string) bool {
+	deadline := time.Now().Add(d)
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	if !display.IsTerminal(os.Stderr) {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+			return true
+		}
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		fmt.Fprintf(os.Stderr, "\r\033[K%s %s", label, time.Until(deadline).Round(time.Second))
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(os.Stderr)
+			return false
+		case <-timer.C:
+			fmt.Fprint(os.Stderr, "\r\033[K")
+			return true
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+)
+
+func TestRestoreSteps(t *testing.T) {
+	infos := []client.DeviceInfo{
+		{Name: "Lamp", Room: "Office", State: map[string]interface{}{
+			"on": true, "brightness": float64(40.4), "colorTemperature": float64(300), "hue": float64(0), "saturation": float64(100),
+		}},
+		{Name: "Door", State: map[string]interface{}{"locked": false, "position": float64(0)}},
+		{Name: "Sensor", State: map[string]interface{}{"temperature": float64(21)}},
+	}
+	tests := []struct {
+		action string
+		want   []restoreStep
+	}{
+		{"off", []restoreStep{{device: "Office/Lamp", action: "on"}}},
+		{"toggle", []restoreStep{{device: "Office/Lamp", action: "on"}}},
+		{"lock", []restoreStep{{device: "Door", action: "unlock"}}},
+		{"color", []restoreStep{{device: "Office/Lamp", action: "color", value: "FF0000"}}},
+		{"brightness", []restoreStep{{device: "Office/Lamp", action: "brightness", value: "40"}}},
+		{"temp", []restoreStep{{device: "Office/Lamp", action: "temp", value: "300"}}},
+		{"open", []restoreStep{{device: "Door", action: "position", value: "0"}}},
+		{"speed", nil},
+	}
+	for _, tt := range tests {
+		if got := restoreSteps(tt.action, infos); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.action, tt.want, got)
+		}
+	}
+
+	off := []client.DeviceInfo{{Name: "Lamp", State: map[string]interface{}{"on": false, "locked": true}}}
+	if got := restoreSteps("on", off); len(got) != 1 || got[0].action != "off" {
+		t.Errorf("expected off, got %+v", got)
+	}
+	if got := restoreSteps("unlock", off); len(got) != 1 || got[0].action != "lock" {
+		t.Errorf("expected lock, got %+v", got)
+	}
+
+	dimmed := []client.DeviceInfo{{Name: "Lamp", State: map[string]interface{}{
+		"on": false, "brightness": float64(30), "hue": float64(0), "position": float64(10),
+	}}}
+	for _, action := range []string{"brightness", "color", "close"} {
+		if got := restoreSteps(action, dimmed); len(got) != 1 || !got[0].off {
+			t.Errorf("%s: expected the light turned off after the restore, got %+v", action, got)
+		}
+	}
+}
+
+// scheduleServer reports Porch/Light as off and records control requests.
+func scheduleServer(t *testing.T, fail string) func() []string {
+	var reqs requestLog
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/info/") {
+			json.NewEncoder(w).Encode(client.DeviceInfo{
+				Name: "Light", Room: "Porch", Reachable: true,
+				State: map[string]interface{}{"on": false, "brightness": float64(30)},
+			})
+			return
+		}
+		reqs.record(r)
+		if fail != "" && strings.HasPrefix(r.URL.Path, fail) {
+			json.NewEncoder(w).Encode(map[string]string{"status": "error", "message": "no response"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	})
+	return reqs.paths
+}
+
+func TestScheduleFor(t *testing.T) {
+	paths := scheduleServer(t, "")
+
+	outputFormat = display.FormatTable
+	out := captureStdout(t, func() {
+		if _, err := executeCmd("on", "Porch/Light", "--for", "20ms"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	if got := strings.Join(paths(), " "); got != "/on/Porch/Light /off/Porch/Light" {
+		t.Errorf("unexpected requests: %s", got)
+	}
+	if out != "success\nrestored Porch/Light\n" {
+		t.Errorf("unexpected output: %q", out)
+	}
+}
+
+func TestScheduleForValueCommand(t *testing.T) {
+	paths := scheduleServer(t, "")
+
+	outputFormat = display.FormatJSON
+	defer func() { outputFormat = display.FormatTable }()
+	out := captureStdout(t, func() {
+		if _, err := executeCmd("brightness", "100", "Porch/Light", "--for", "10ms", "--json"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	if got := strings.Join(paths(), " "); got != "/brightness/100/Porch/Light /brightness/30/Porch/Light /off/Porch/Light" {
+		t.Errorf("unexpected requests: %s", got)
+	}
+	if strings.Contains(out, "restored") {
+		t.Errorf("expected only JSON output, got %q", out)
+	}
+}
+
+func TestScheduleAfter(t *testing.T) {
+	paths := scheduleServer(t, "")
+
+	outputFormat = display.FormatTable
+	start := time.Now()
+	captureStdout(t, func() {
+		if _, err := executeCmd("off", "Porch/Light", "--after", "30ms"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("expected to wait at least 30ms, waited %s", elapsed)
+	}
+	if got := strings.Join(paths(), " "); got != "/off/Porch/Light" {
+		t.Errorf("unexpected requests: %s", got)
+	}
+}
+
+func TestScheduleAfterCancelled(t *testing.T) {
+	paths := scheduleServer(t, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := executeCmdContext(ctx, "off", "Porch/Light", "--after", "1h")
+	if err == nil || err.Error() != "cancelled before running off Porch/Light" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(paths()) != 0 {
+		t.Errorf("expected no requests, got %v", paths())
+	}
+}
+
+func TestScheduleForInterruptedRestoresNow(t *testing.T) {
+	paths := scheduleServer(t, "")
+
+	outputFormat = display.FormatTable
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	captureStdout(t, func() {
+		if _, err := executeCmdContext(ctx, "on", "Porch/Light", "--for", "1h"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	if got := strings.Join(paths(), " "); got != "/on/Porch/Light /off/Porch/Light" {
+		t.Errorf("unexpected requests: %s", got)
+	}
+}
+
+func TestScheduleErrors(t *testing.T) {
+	scheduleServer(t, "/on/")
+
+	tests := []struct {
+		args   []string
+		errMsg string
+	}{
+		{[]string{"on", "Lamp", "--after", "-1s"}, "--after and --for must not be negative"},
+		{[]string{"scene", "Goodnight", "--for", "1m"}, "--for is not supported for scenes"},
+		{[]string{"speed", "50", "Porch/Light", "--for", "1m"}, "cannot use --for: no device in Porch/Light reports a state to restore"},
+		{[]string{"on", "Porch/Light", "--for", "1m"}, "no response"},
+	}
+	for _, tt := range tests {
+		_, err := executeCmd(tt.args...)
+		if err == nil || err.Error() != tt.errMsg {
+			t.Errorf("%v: expected %q, got %v", tt.args, tt.errMsg, err)
+		}
+	}
+}
+
+func TestScheduleRestoreFails(t *testing.T) {
+	scheduleServer(t, "/off/")
+
+	outputFormat = display.FormatTable
+	var err error
+	captureStdout(t, func() {
+		_, err = executeCmd("on", "Porch/Light", "--for", "10ms")
+	})
+	if err == nil || err.Error() != "failed to restore 1 of 1 devices" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestScheduleInfoError(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	})
+
+	if _, err := executeCmd("on", "Lamp", "--for", "1m"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestScheduleConfigError(t *testing.T) {
+	setupTestEnv(t, nil)
+	t.Setenv("ITSYHOME_PORT", "bogus")
+
+	if _, err := executeCmd("on", "Lamp", "--for", "1m"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestCountdownTicks(t *testing.T) {
+	fakeTerminal(t)
+	var done bool
+	out := captureStderr(t, func() {
+		done = countdown(context.Background(), 1100*time.Millisecond, "Waiting")
+	})
+	if !done {
+		t.Error("expected countdown to complete")
+	}
+	if !strings.Contains(out, "\r\033[KWaiting 1s") {
+		t.Errorf("expected countdown on a terminal, got %q", out)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	captureStderr(t, func() { done = countdown(ctx, time.Hour, "Waiting") })
+	if done {
+		t.Error("expected cancelled countdown to return false")
+	}
+}
+
+func TestCountdownWithoutTerminal(t *testing.T) {
+	var done bool
+	out := captureStderr(t, func() {
+		done = countdown(context.Background(), time.Millisecond, "Waiting")
+	})
+	if !done || out != "" {
+		t.Errorf("expected a silent countdown outside a terminal, got %v, %q", done, out)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if countdown(ctx, time.Hour, "Waiting") {
+		t.Error("expected cancelled countdown to return false")
+	}
+}
@@ -1,27 +1,31 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
 
 	"github.com/nickustinov/itsyhome-cli/internal/client"
-	"github.com/nickustinov/itsyhome-cli/internal/config"
 	"github.com/nickustinov/itsyhome-cli/internal/display"
 	"github.com/spf13/cobra"
 )
 
 var statusCmd = &cobra.Command{
-	Use:   "status [room]",
-	Short: "Show home status summary, or device states for a room",
+	Use:               "status [room]",
+	Short:             "Show home status summary, or device states for a room",
+	ValidArgsFunction: completeTarget(completeRoomNames),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		c := client.New(config.Load())
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
 
 		if len(args) > 0 {
-			return showRoomStatus(c, strings.Join(args, " "))
+			return showRoomStatus(cmd.Context(), c, strings.Join(args, " "))
 		}
 
-		return showHomeStatus(c)
+		return showHomeStatus(cmd.Context(), c)
 	},
 }
 
@@ -35,6 +39,7 @@ type statusDevice struct {
 type statusRoom struct {
 	Room    string         `json:"room"`
 	Devices []statusDevice `json:"devices"`
+	Error   string         `json:"error,omitempty"`
 }
 
 type statusOutput struct {
@@ -44,25 +49,30 @@ type statusOutput struct {
 	Details     []statusRoom `json:"details"`
 }
 
-func showHomeStatus(c *client.Client) error {
-	status, err := c.GetStatus()
+func showHomeStatus(ctx context.Context, c *client.Client) error {
+	status, err := c.GetStatusContext(ctx)
 	if err != nil {
 		return err
 	}
 
-	rooms, err := c.ListRooms()
+	rooms, err := c.ListRoomsContext(ctx)
 	if err != nil {
 		return err
 	}
 
+	results := fetchRoomInfos(ctx, c, rooms, statusConcurrency)
+
 	details := make([]statusRoom, len(rooms))
 	maxName, maxType := 0, 0
+	failed := 0
 
 	for i, room := range rooms {
-		infos, err := c.GetInfo(room.Name)
-		if err != nil {
-			return err
+		if results[i].err != nil {
+			details[i] = statusRoom{Room: room.Name, Devices: []statusDevice{}, Error: results[i].err.Error()}
+			failed++
+			continue
 		}
+		infos := results[i].infos
 		devices := make([]statusDevice, len(infos))
 		for j, info := range infos {
 			state := deviceState(info)
@@ -84,23 +94,15 @@ func showHomeStatus(c *client.Client) error {
 		details[i] = statusRoom{Room: room.Name, Devices: devices}
 	}
 
-	if jsonOutput {
-		out := statusOutput{
-			Rooms:       status.Rooms,
-			Devices:     status.Devices,
-			Unreachable: status.Unreachable,
-			Details:     details,
-		}
-		data, _ := json.MarshalIndent(out, "", "  ")
-		fmt.Println(string(data))
-		return nil
-	}
-
 	header := fmt.Sprintf("Home (%d rooms, %d devices, %d unreachable)",
 		status.Rooms, status.Devices, status.Unreachable)
 
 	roomNodes := make([]display.TreeNode, len(rooms))
 	for i, room := range rooms {
+		if details[i].Error != "" {
+			roomNodes[i] = display.TreeNode{Label: fmt.Sprintf("%s (error: %s)", room.Name, details[i].Error)}
+			continue
+		}
 		deviceNodes := make([]display.TreeNode, len(details[i].Devices))
 		for j, dev := range details[i].Devices {
 			label := fmt.Sprintf("%-*s  %-*s  %s",
@@ -116,8 +118,52 @@ func showHomeStatus(c *client.Client) error {
 	}
 
 	tree := &display.Tree{Root: display.TreeNode{Label: header, Children: roomNodes}}
-	fmt.Print(tree.Render())
-	return nil
+	err = render(display.Output{
+		Data: statusOutput{
+			Rooms:       status.Rooms,
+			Devices:     status.Devices,
+			Unreachable: status.Unreachable,
+			Details:     details,
+		},
+		Table: statusTable(details),
+		Text:  tree.Render(),
+	})
+	if failed > 0 {
+		return fmt.Errorf("failed to fetch %d of %d rooms", failed, len(rooms))
+	}
+	return err
+}
+
+// statusTable flattens the home status to one row per device for the
+// tabular formats. A room that could not be fetched gets a single row.
+func statusTable(details []statusRoom) *display.Table {
+	tbl := display.NewTable("Room", "Device", "Type", "State", "Value")
+	for _, room := range details {
+		if room.Error != "" {
+			tbl.AddRow(room.Room, "", "", "error: "+room.Error, "")
+			continue
+		}
+		for _, dev := range room.Devices {
+			tbl.AddRow(room.Room, dev.Name, dev.Type, dev.State, dev.Value)
+		}
+	}
+	return tbl
+}
+
+type roomResult struct {
+	infos []client.DeviceInfo
+	err   error
+}
+
+// fetchRoomInfos calls GetInfo for every room using at most concurrency
+// requests in flight. Results are returned in the same order as rooms.
+func fetchRoomInfos(ctx context.Context, c *client.Client, rooms []client.Room, concurrency int) []roomResult {
+	results := make([]roomResult, len(rooms))
+	parallel(len(rooms), concurrency, func(i int) {
+		infos, err := c.GetInfoContext(ctx, rooms[i].Name)
+		results[i] = roomResult{infos: infos, err: err}
+	})
+	return results
 }
 
 func deviceState(info client.DeviceInfo) string {
@@ -132,18 +178,12 @@ func deviceState(info client.DeviceInfo) string {
 	return "off"
 }
 
-func showRoomStatus(c *client.Client, target string) error {
-	infos, err := c.GetInfo(target)
+func showRoomStatus(ctx context.Context, c *client.Client, target string) error {
+	infos, err := c.GetInfoContext(ctx, target)
 	if err != nil {
 		return err
 	}
 
-	if jsonOutput {
-		data, _ := json.MarshalIndent(infos, "", "  ")
-		fmt.Println(string(data))
-		return nil
-	}
-
 	tbl := display.NewTable("Device", "State", "Value")
 	for _, info := range infos {
 		state := "off"
@@ -159,8 +199,7 @@ func showRoomStatus(c *client.Client, target string) error {
 
 		tbl.AddRow(info.Name, state, value)
 	}
-	fmt.Print(tbl.Render())
-	return nil
+	return render(display.Output{Data: infos, Table: tbl})
 }
 
 func formatValue(info client.DeviceInfo) string {
@@ -180,6 +219,9 @@ func formatValue(info client.DeviceInfo) string {
 	if p, ok := info.State["position"]; ok {
 		parts = append(parts, fmt.Sprintf("%.0f%%", toFloat(p)))
 	}
+	if ct, ok := info.State["colorTemperature"]; ok {
+		parts = append(parts, fmt.Sprintf("%dK", miredsToKelvin(toFloat(ct))))
+	}
 	if h, ok := info.State["humidity"]; ok {
 		parts = append(parts, fmt.Sprintf("%.0f%% RH", toFloat(h)))
 	}
@@ -215,6 +257,9 @@ func toFloat(v interface{}) float64 {
 	return 0
 }
 
+var statusConcurrency int
+
 func init() {
+	statusCmd.Flags().IntVar(&statusConcurrency, "concurrency", 4, "Maximum number of rooms fetched in parallel")
 	rootCmd.AddCommand(statusCmd)
 }
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+	"github.com/spf13/cobra"
+)
+
+// targetConcurrency caps the requests in flight when controlling several
+// targets at once.
+const targetConcurrency = 8
+
+type targetFlags struct {
+	targets  []string
+	list     []string
+	from     string
+	match    string
+	yes      bool
+	patterns bool
+}
+
+// addTargetFlags adds the multi-target flags to cmd. With patterns set,
+// targets may also be globs or regular expressions, expanded by expand.
+func addTargetFlags(cmd *cobra.Command, tf *targetFlags, patterns bool) {
+	cmd.Flags().StringArrayVarP(&tf.targets, "target", "t", nil, "Additional target, taken literally (repeatable)")
+	cmd.Flags().StringSliceVar(&tf.list, "targets", nil, "Additional targets as a comma-separated list (quote names containing commas)")
+	cmd.Flags().StringVar(&tf.from, "targets-from", "", "Read additional targets from a file, one per line (- for stdin)")
+	if patterns {
+		tf.patterns = true
+		cmd.Flags().StringVar(&tf.match, "match", "", "Select devices by property, e.g. type=light,room=Kitchen")
+		cmd.Flags().BoolVar(&tf.yes, "yes", false, fmt.Sprintf("Allow patterns that match more than %d targets", confirmAbove))
+	}
+}
+
+// resolve combines the positional target with -t, --targets and --targets-from,
+// dropping duplicates but keeping the order they were given in, and
+// expands any patterns.
+func (tf targetFlags) resolve(cmd *cobra.Command, positional []string) ([]string, error) {
+	var all []string
+	if len(positional) > 0 {
+		all = append(all, strings.Join(positional, " "))
+	}
+	all = append(all, tf.targets...)
+	all = append(all, tf.list...)
+	if tf.from != "" {
+		var r io.Reader = cmd.InOrStdin()
+		if tf.from != "-" {
+			f, err := os.Open(tf.from)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			r = f
+		}
+		sc := bufio.NewScanner(r)
+		for sc.Scan() {
+			if line := sc.Text(); !strings.HasPrefix(strings.TrimSpace(line), "#") {
+				all = append(all, line)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := map[string]bool{}
+	var targets []string
+	for _, t := range all {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		targets = append(targets, t)
+	}
+	if len(targets) == 0 && tf.match == "" {
+		return nil, fmt.Errorf("a target is required: give it as an argument or with -t")
+	}
+	if tf.patterns {
+		return tf.expand(cmd.Context(), targets)
+	}
+	return targets, nil
+}
+
+type targetResult struct {
+	Target string `json:"target"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// controlTargets runs fn for every target concurrently, prints a result
+// table and fails if any target failed.
+func controlTargets(ctx context.Context, targets []string, fn func(ctx context.Context, c *client.Client, target string) (string, error)) error {
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	results := make([]targetResult, len(targets))
+	parallel(len(targets), targetConcurrency, func(i int) {
+		results[i].Target = targets[i]
+		status, err := fn(ctx, c, targets[i])
+		if err != nil {
+			results[i].Status = "error"
+			results[i].Error = err.Error()
+			return
+		}
+		results[i].Status = status
+	})
+
+	failed := 0
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+		}
+	}
+
+	tbl := display.NewTable("Target", "Status")
+	for _, r := range results {
+		status := r.Status
+		if r.Error != "" {
+			status = "error: " + r.Error
+		}
+		tbl.AddRow(r.Target, status)
+	}
+	err = render(display.Output{Data: results, Table: tbl})
+	if failed > 0 {
+		return fmt.Errorf("failed on %d of %d targets", failed, len(targets))
+	}
+	return err
+}
+
+// parallel calls fn(0..n-1) with at most limit calls running at once and
+// returns when all have finished.
+func parallel(n, limit int, fn func(i int)) {
+	if limit < 1 {
+		limit = 1
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// adjustStatus summarises adjustTarget results for the multi-target table.
+func adjustStatus(results []adjustment) (string, error) {
+	failed := 0
+	for _, a := range results {
+		if a.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return "", fmt.Errorf("failed to adjust %d of %d devices", failed, len(results))
+	}
+	if len(results) == 1 {
+		return fmt.Sprintf("%s (%d -> %d)", results[0].Status, results[0].Old, results[0].New), nil
+	}
+	return fmt.Sprintf("%s (%d devices)", results[0].Status, len(results)), nil
+}
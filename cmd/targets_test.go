@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+)
+
+func TestResolveTargets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	if err := os.WriteFile(path, []byte("# porch\nPorch/Light\n\n  Garden/Lamp  \nKitchen/Light\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tf := targetFlags{targets: []string{"Kitchen/Light", "Porch, Front", " Office "}, list: []string{"Hall/Light", "Office"}, from: path}
+	got, err := tf.resolve(rootCmd, []string{"Living", "Room"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"Living Room", "Kitchen/Light", "Porch, Front", "Office", "Hall/Light", "Porch/Light", "Garden/Lamp"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestResolveTargetsErrors(t *testing.T) {
+	if _, err := (targetFlags{}).resolve(rootCmd, nil); err == nil || !strings.Contains(err.Error(), "a target is required") {
+		t.Errorf("expected missing target error, got %v", err)
+	}
+	if _, err := (targetFlags{targets: []string{" "}, list: []string{""}}).resolve(rootCmd, nil); err == nil {
+		t.Error("expected error for empty targets")
+	}
+	if _, err := (targetFlags{from: filepath.Join(t.TempDir(), "missing")}).resolve(rootCmd, nil); err == nil {
+		t.Error("expected error for missing file")
+	}
+
+	rootCmd.SetIn(errorReader{})
+	defer rootCmd.SetIn(nil)
+	if _, err := (targetFlags{from: "-"}).resolve(rootCmd, nil); err == nil || err.Error() != "read error" {
+		t.Errorf("expected read error, got %v", err)
+	}
+}
+
+func TestControlCmdMissingTarget(t *testing.T) {
+	_, err := executeCmd("on")
+	if err == nil || !strings.Contains(err.Error(), "a target is required") {
+		t.Fatalf("expected missing target error, got %v", err)
+	}
+}
+
+func TestControlMultipleTargets(t *testing.T) {
+	paths := runServer(t)
+
+	outputFormat = display.FormatTable
+	var err error
+	out := captureStdout(t, func() {
+		_, err = executeCmd("off", "Kitchen/Light", "--targets", "Hall/Light,Broken", "--target", "Porch/Light")
+	})
+	if err == nil || err.Error() != "failed on 1 of 4 targets" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := paths()
+	sort.Strings(got)
+	want := []string{"/off/Broken", "/off/Hall/Light", "/off/Kitchen/Light", "/off/Porch/Light"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	for _, s := range []string{"Target", "Kitchen/Light", "success", "Broken", "error: device not found"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected %q in output, got %q", s, out)
+		}
+	}
+}
+
+func TestControlTargetsFromStdinJSON(t *testing.T) {
+	paths := runServer(t)
+	rootCmd.SetIn(strings.NewReader("Kitchen/Light\nHall/Light\n"))
+	defer rootCmd.SetIn(nil)
+
+	out := captureStdout(t, func() {
+		if _, err := executeCmd("brightness", "--json", "40", "--targets-from", "-"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	outputFormat = display.FormatTable
+
+	var results []targetResult
+	if err := json.Unmarshal([]byte(out), &results); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out, err)
+	}
+	want := []targetResult{{Target: "Kitchen/Light", Status: "success"}, {Target: "Hall/Light", Status: "success"}}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("expected %+v, got %+v", want, results)
+	}
+	if len(paths()) != 2 {
+		t.Errorf("expected 2 requests, got %v", paths())
+	}
+}
+
+func TestControlMultipleTargetsValueError(t *testing.T) {
+	runServer(t)
+
+	outputFormat = display.FormatTable
+	var err error
+	captureStdout(t, func() {
+		_, err = executeCmd("brightness", "40", "Lamp", "-t", "Broken")
+	})
+	if err == nil || err.Error() != "failed on 1 of 2 targets" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAdjustMultipleTargets(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		var infos []client.DeviceInfo
+		switch r.URL.Path {
+		case "/info/Office":
+			infos = []client.DeviceInfo{
+				{Name: "Lamp", Room: "Office", State: map[string]interface{}{"brightness": float64(40)}},
+				{Name: "Strip", Room: "Office", State: map[string]interface{}{"brightness": float64(10)}},
+			}
+		case "/info/Hall/Light":
+			infos = []client.DeviceInfo{{Name: "Light", Room: "Hall", State: map[string]interface{}{"brightness": float64(20)}}}
+		case "/info/Porch/Light":
+			infos = []client.DeviceInfo{{Name: "Light", Room: "Porch", State: map[string]interface{}{"brightness": float64(20)}}}
+		case "/info/Fan":
+			infos = []client.DeviceInfo{{Name: "Fan", State: map[string]interface{}{"speed": float64(20)}}}
+		default:
+			if strings.HasPrefix(r.URL.Path, "/brightness/") && strings.HasSuffix(r.URL.Path, "/Porch/Light") {
+				json.NewEncoder(w).Encode(map[string]string{"status": "error", "message": "no response"})
+				return
+			}
+		}
+		if infos != nil {
+			json.NewEncoder(w).Encode(infos)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	})
+
+	outputFormat = display.FormatTable
+	var err error
+	out := captureStdout(t, func() {
+		_, err = executeCmd("brightness", "+10", "--targets", "Office,Hall/Light,Porch/Light,Fan")
+	})
+	if err == nil || err.Error() != "failed on 2 of 4 targets" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, s := range []string{"success (2 devices)", "success (20 -> 30)", "failed to adjust 1 of 1 devices", "no device in Fan reports brightness"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected %q in output, got %q", s, out)
+		}
+	}
+}
+
+func TestScheduleForMultipleTargets(t *testing.T) {
+	paths := scheduleServer(t, "")
+
+	outputFormat = display.FormatTable
+	out := captureStdout(t, func() {
+		if _, err := executeCmd("on", "-t", "Porch/Light", "-t", "Garden/Light", "--for", "20ms"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	got := paths()
+	if len(got) != 4 || got[2] != "/off/Porch/Light" || got[3] != "/off/Porch/Light" {
+		t.Errorf("unexpected requests: %v", got)
+	}
+	if !strings.Contains(out, "Garden/Light") || strings.Count(out, "restored Porch/Light") != 2 {
+		t.Errorf("unexpected output: %q", out)
+	}
+}
+
+func TestControlTargetWithComma(t *testing.T) {
+	paths := runServer(t)
+
+	outputFormat = display.FormatTable
+	captureStdout(t, func() {
+		if _, err := executeCmd("off", "-t", "Porch, Front", "--targets", `"Hall, Upstairs",Kitchen/Light`); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	got := paths()
+	sort.Strings(got)
+	want := []string{"/off/Hall, Upstairs", "/off/Kitchen/Light", "/off/Porch, Front"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestControlTargetsConfigError(t *testing.T) {
+	setupTestEnv(t, nil)
+	t.Setenv("ITSYHOME_PORT", "bogus")
+
+	if _, err := executeCmd("on", "-t", "Lamp", "-t", "Fan"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestParallelLimit(t *testing.T) {
+	var running, peak int32
+	seen := make([]bool, 10)
+	parallel(len(seen), 3, func(i int) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		seen[i] = true
+		atomic.AddInt32(&running, -1)
+	})
+	if peak > 3 {
+		t.Errorf("expected at most 3 concurrent calls, got %d", peak)
+	}
+	for i, ok := range seen {
+		if !ok {
+			t.Errorf("index %d not run", i)
+		}
+	}
+
+	calls := 0
+	parallel(2, 0, func(int) { calls++ })
+	if calls != 2 {
+		t.Errorf("expected 2 calls with limit 0, got %d", calls)
+	}
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+)
+
+// transitionActions are the value commands that accept --over.
+var transitionActions = map[string]bool{
+	"brightness": true,
+	"temp":       true,
+	"position":   true,
+}
+
+type transition struct {
+	over  time.Duration
+	step  time.Duration
+	curve string
+}
+
+// curves map progress in [0,1] to the fraction of the change applied.
+var curves = map[string]func(float64) float64{
+	"linear": func(t float64) float64 {
+		return t
+	},
+	"ease-in": func(t float64) float64 {
+		return t * t * t
+	},
+	"ease-out": func(t float64) float64 {
+		return 1 - math.Pow(1-t, 3)
+	},
+	"ease-in-out": func(t float64) float64 {
+		if t < 0.5 {
+			return 4 * t * t * t
+		}
+		return 1 - math.Pow(-2*t+2, 3)/2
+	},
+}
+
+func curveNames() string {
+	names := make([]string, 0, len(curves))
+	for name := range curves {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func (tr transition) validate() error {
+	if tr.over < 0 {
+		return fmt.Errorf("--over must not be negative")
+	}
+	if tr.step <= 0 {
+		return fmt.Errorf("--step must be positive")
+	}
+	if _, ok := curves[tr.curve]; !ok {
+		return fmt.Errorf("unknown curve %q: expected one of %s", tr.curve, curveNames())
+	}
+	return nil
+}
+
+// runTransition moves every device from Old to New along the curve, sending
+// a request whenever a device's rounded value changes. If ctx is cancelled
+// first, each adjustment is marked interrupted with New set to the value the
+// device was left at.
+func runTransition(ctx context.Context, c *client.Client, action string, results []adjustment, tr transition) {
+	curve := curves[tr.curve]
+	last := make([]int, len(results))
+	for i, a := range results {
+		last[i] = a.Old
+		results[i].Status = "unchanged"
+	}
+
+	ticker := time.NewTicker(tr.step)
+	defer ticker.Stop()
+	start := time.Now()
+
+	for {
+		progress := math.Min(float64(time.Since(start))/float64(tr.over), 1)
+		f := curve(progress)
+		for i := range results {
+			a := &results[i]
+			v := int(math.Round(float64(a.Old) + float64(a.New-a.Old)*f))
+			if v != last[i] && sendAdjustment(ctx, c, action, a, v) {
+				last[i] = v
+			}
+		}
+		if progress >= 1 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			for i := range results {
+				results[i].New = last[i]
+				results[i].Status = "interrupted"
+				results[i].Error = ""
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
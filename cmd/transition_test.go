@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+)
+
+func TestCurves(t *testing.T) {
+	for name, curve := range curves {
+		if curve(0) != 0 || curve(1) != 1 {
+			t.Errorf("%s: expected 0 and 1 at the ends, got %v and %v", name, curve(0), curve(1))
+		}
+	}
+	if v := curves["linear"](0.25); v != 0.25 {
+		t.Errorf("linear(0.25) = %v", v)
+	}
+	if v := curves["ease-in"](0.5); v != 0.125 {
+		t.Errorf("ease-in(0.5) = %v", v)
+	}
+	if v := curves["ease-out"](0.5); v != 0.875 {
+		t.Errorf("ease-out(0.5) = %v", v)
+	}
+	if v := curves["ease-in-out"](0.25); v != 0.0625 {
+		t.Errorf("ease-in-out(0.25) = %v", v)
+	}
+	if v := curves["ease-in-out"](0.75); math.Abs(v-0.9375) > 1e-9 {
+		t.Errorf("ease-in-out(0.75) = %v", v)
+	}
+}
+
+func TestTransitionValidate(t *testing.T) {
+	tests := []struct {
+		args   []string
+		errMsg string
+	}{
+		{[]string{"brightness", "--over", "-1s", "50", "Lamp"}, "--over must not be negative"},
+		{[]string{"brightness", "--step", "0s", "50", "Lamp"}, "--step must be positive"},
+		{[]string{"temp", "--curve", "bounce", "300", "Lamp"}, `unknown curve "bounce": expected one of ease-in, ease-in-out, ease-out, linear`},
+		{[]string{"position", "--force", "--over", "1s", "half", "Blinds"}, `--over needs a whole number, got "half"`},
+	}
+	for _, tt := range tests {
+		_, err := executeCmd(tt.args...)
+		if err == nil || err.Error() != tt.errMsg {
+			t.Errorf("%v: expected %q, got %v", tt.args, tt.errMsg, err)
+		}
+	}
+}
+
+func TestTransitionFlagsOnlyOnSupportedCommands(t *testing.T) {
+	_, err := executeCmd("speed", "--over", "1s", "50", "Fan")
+	if err == nil || !strings.Contains(err.Error(), "unknown flag: --over") {
+		t.Errorf("expected unknown flag error, got %v", err)
+	}
+}
+
+// transitionServer reports brightness 100 for Lamp and records every value sent.
+func transitionServer(t *testing.T, failFirst bool) (*[]int, *sync.Mutex) {
+	var (
+		mu     sync.Mutex
+		values []int
+		failed bool
+	)
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/info/") {
+			json.NewEncoder(w).Encode(client.DeviceInfo{
+				Name: "Lamp", Room: "Bedroom", Reachable: true,
+				State: map[string]interface{}{"brightness": float64(100)},
+			})
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if failFirst && !failed {
+			failed = true
+			json.NewEncoder(w).Encode(map[string]string{"status": "error", "message": "busy"})
+			return
+		}
+		parts := strings.Split(r.URL.Path, "/")
+		v, _ := strconv.Atoi(parts[2])
+		values = append(values, v)
+		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	})
+	return &values, &mu
+}
+
+func TestTransitionFadesToTarget(t *testing.T) {
+	values, _ := transitionServer(t, false)
+
+	outputFormat = display.FormatTable
+	out := captureStdout(t, func() {
+		if _, err := executeCmd("brightness", "0", "Bedroom/Lamp", "--over", "60ms", "--step", "10ms", "--curve", "ease-in-out"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	if strings.TrimSpace(out) != "success" {
+		t.Errorf("unexpected output: %q", out)
+	}
+	got := *values
+	if len(got) < 2 || got[len(got)-1] != 0 {
+		t.Fatalf("expected several steps ending at 0, got %v", got)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] >= got[i-1] {
+			t.Errorf("expected strictly decreasing values, got %v", got)
+		}
+	}
+}
+
+func TestTransitionRetriesFailedStep(t *testing.T) {
+	values, _ := transitionServer(t, true)
+
+	outputFormat = display.FormatTable
+	captureStdout(t, func() {
+		if _, err := executeCmd("brightness", "-40", "Lamp", "--over", "50ms", "--step", "10ms"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	got := *values
+	if len(got) == 0 || got[len(got)-1] != 60 {
+		t.Errorf("expected the fade to recover and end at 60, got %v", got)
+	}
+}
+
+func TestTransitionUnchanged(t *testing.T) {
+	values, _ := transitionServer(t, false)
+
+	outputFormat = display.FormatTable
+	out := captureStdout(t, func() {
+		if _, err := executeCmd("brightness", "100", "Lamp", "--over", "20ms", "--step", "10ms"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	if len(*values) != 0 {
+		t.Errorf("expected no requests, got %v", *values)
+	}
+	if strings.TrimSpace(out) != "unchanged" {
+		t.Errorf("unexpected output: %q", out)
+	}
+}
+
+func TestTransitionInterrupted(t *testing.T) {
+	values, mu := transitionServer(t, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var err error
+	out := captureStdout(t, func() {
+		_, err = executeCmdContext(ctx, "brightness", "0", "Lamp", "--over", "1h", "--step", "10ms", "--json")
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var results []adjustment
+	if jerr := json.Unmarshal([]byte(out), &results); jerr != nil {
+		t.Fatalf("invalid JSON %q: %v", out, jerr)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(results) != 1 || results[0].Status != "interrupted" || results[0].New != 100 || len(*values) != 0 {
+		t.Errorf("expected the lamp to be left at 100, got %+v after %v", results, *values)
+	}
+	outputFormat = display.FormatTable
+}
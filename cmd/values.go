@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nickustinov/itsyhome-cli/internal/color"
+)
+
+type valueRange struct {
+	min, max int
+	unit     string
+	// stateKey is the DeviceInfo.State property holding the current value.
+	stateKey string
+}
+
+var valueRanges = map[string]valueRange{
+	"brightness": {0, 100, "%", "brightness"},
+	"position":   {0, 100, "%", "position"},
+	"speed":      {0, 100, "%", "speed"},
+	"temp":       {140, 500, " mireds (about 2000-7150K)", "colorTemperature"},
+}
+
+// valueParsers convert friendlier input to the form the server expects.
+// Unlike the validators they also run with --force.
+var valueParsers = map[string]func(string) (string, error){
+	"temp":  parseColorTemp,
+	"color": parseColor,
+}
+
+// valueValidators check and normalise the value argument of each value
+// control command before it is sent. --force skips them.
+var valueValidators = map[string]func(string) (string, error){
+	"brightness": intRange("brightness"),
+	"position":   intRange("position"),
+	"speed":      intRange("speed"),
+	"temp":       intRange("temp"),
+}
+
+func intRange(action string) func(string) (string, error) {
+	r := valueRanges[action]
+	return func(value string) (string, error) {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s %q: expected a whole number from %d to %d%s, or a relative value like +10", action, value, r.min, r.max, r.unit)
+		}
+		if n < r.min || n > r.max {
+			return "", fmt.Errorf("%s %d is out of range: valid values are %d-%d%s (use --force for devices with non-standard ranges)", action, n, r.min, r.max, r.unit)
+		}
+		return strconv.Itoa(n), nil
+	}
+}
+
+func parseColor(value string) (string, error) {
+	c, err := color.Parse(value)
+	if err != nil {
+		return "", err
+	}
+	return c.Hex(), nil
+}
+
+var relativeValue = regexp.MustCompile(`^[+-]\d+(\.\d+)?%?$`)
+
+func isRelative(value string) bool {
+	return relativeValue.MatchString(value)
+}
+
+// apply adds a relative value such as "+10" or "-25%" to current. A trailing
+// % is a percentage of the valid range, which for 0-100 controls is the same
+// as the plain number. The result is clamped to the range unless force is set.
+func (r valueRange) apply(current float64, value string, force bool) int {
+	percent := strings.HasSuffix(value, "%")
+	delta, _ := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if percent {
+		delta = delta * float64(r.max-r.min) / 100
+	}
+	n := int(math.Round(current + delta))
+	if force {
+		return n
+	}
+	if n < r.min {
+		return r.min
+	}
+	if n > r.max {
+		return r.max
+	}
+	return n
+}
+
+var whitePoints = map[string]int{
+	"warm":     2700,
+	"neutral":  4000,
+	"cool":     5000,
+	"daylight": 6500,
+}
+
+// parseColorTemp converts Kelvin ("2700K") and named white points ("warm")
+// to mireds. Anything else is assumed to be mireds already.
+func parseColorTemp(value string) (string, error) {
+	lower := strings.ToLower(value)
+	if k, ok := whitePoints[lower]; ok {
+		return strconv.Itoa(kelvinToMireds(k)), nil
+	}
+	if !strings.HasSuffix(lower, "k") {
+		return value, nil
+	}
+	k, err := strconv.Atoi(strings.TrimSuffix(lower, "k"))
+	if err != nil || k <= 0 {
+		return "", fmt.Errorf("invalid color temperature %q: expected mireds (140-500), Kelvin such as 2700K, or one of warm, neutral, cool, daylight", value)
+	}
+	return strconv.Itoa(kelvinToMireds(k)), nil
+}
+
+func kelvinToMireds(k int) int {
+	return int(math.Round(1e6 / float64(k)))
+}
+
+func miredsToKelvin(m float64) int {
+	if m <= 0 {
+		return 0
+	}
+	return int(math.Round(1e6 / m))
+}
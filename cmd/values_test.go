@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+)
+
+func TestValueValidators(t *testing.T) {
+	tests := []struct {
+		action string
+		value  string
+		want   string
+		errMsg string
+	}{
+		{"brightness", "0", "0", ""},
+		{"brightness", "100", "100", ""},
+		{"brightness", "150", "", "valid values are 0-100%"},
+		{"position", "-1", "", "valid values are 0-100%"},
+		{"speed", "fast", "", `invalid speed "fast"`},
+		{"temp", "370", "370", ""},
+		{"temp", "22", "", "valid values are 140-500 mireds"},
+	}
+	for _, tt := range tests {
+		got, err := valueValidators[tt.action](tt.value)
+		if tt.errMsg != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("%s %q: expected error containing %q, got %v", tt.action, tt.value, tt.errMsg, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %q: unexpected error: %v", tt.action, tt.value, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s %q: expected %q, got %q", tt.action, tt.value, tt.want, got)
+		}
+	}
+}
+
+func TestValueControlCmdRejectsOutOfRange(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s", r.URL.Path)
+	})
+
+	_, err := executeCmd("brightness", "150", "Lamp")
+	if err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Fatalf("expected range error mentioning --force, got %v", err)
+	}
+}
+
+func TestColorCmdParsesInput(t *testing.T) {
+	var paths []string
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	})
+
+	outputFormat = display.FormatTable
+	for _, value := range []string{"#ff6600", "orange", "#f60", "rgb(255,100,0)", "hsl(30,100%,50%)"} {
+		if _, err := executeCmd("color", value, "Lamp"); err != nil {
+			t.Fatalf("%s: unexpected error: %v", value, err)
+		}
+	}
+	want := "/color/FF6600/Lamp /color/FFA500/Lamp /color/FF6600/Lamp /color/FF6400/Lamp /color/FF8000/Lamp"
+	if strings.Join(paths, " ") != want {
+		t.Errorf("expected %s, got %v", want, paths)
+	}
+
+	_, err := executeCmd("color", "blurple", "Lamp")
+	if err == nil || !strings.Contains(err.Error(), `invalid color "blurple"`) {
+		t.Errorf("expected invalid color error, got %v", err)
+	}
+}
+
+func TestValueControlCmdForce(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/position/120/Garage/Door" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	})
+
+	outputFormat = display.FormatTable
+	if _, err := executeCmd("position", "--force", "120", "Garage/Door"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValueRangeApply(t *testing.T) {
+	tests := []struct {
+		action  string
+		current float64
+		value   string
+		force   bool
+		want    int
+	}{
+		{"brightness", 40, "+10", false, 50},
+		{"brightness", 95, "+10", false, 100},
+		{"brightness", 5, "-50", false, 0},
+		{"position", 50, "+25%", false, 75},
+		{"speed", 33.4, "+0.4", false, 34},
+		{"temp", 300, "-50", false, 250},
+		{"temp", 300, "+10%", false, 336},
+		{"temp", 480, "+50", false, 500},
+		{"temp", 160, "-50", false, 140},
+		{"brightness", 95, "+10", true, 105},
+	}
+	for _, tt := range tests {
+		got := valueRanges[tt.action].apply(tt.current, tt.value, tt.force)
+		if got != tt.want {
+			t.Errorf("%s %v %s: expected %d, got %d", tt.action, tt.current, tt.value, tt.want, got)
+		}
+	}
+}
+
+func TestIsRelative(t *testing.T) {
+	for _, v := range []string{"+10", "-50", "+25%", "-2.5"} {
+		if !isRelative(v) {
+			t.Errorf("expected %q to be relative", v)
+		}
+	}
+	for _, v := range []string{"10", "+", "+ten", "25%", "#FF6600"} {
+		if isRelative(v) {
+			t.Errorf("expected %q not to be relative", v)
+		}
+	}
+}
+
+func TestValueControlCmdHelp(t *testing.T) {
+	out, err := executeCmd("brightness", "--help")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "--force") {
+		t.Errorf("expected help output, got %q", out)
+	}
+}
+
+func TestValueControlCmdUnknownFlag(t *testing.T) {
+	_, err := executeCmd("brightness", "--bogus", "50", "Lamp")
+	if err == nil || !strings.Contains(err.Error(), "unknown flag") {
+		t.Fatalf("expected unknown flag error, got %v", err)
+	}
+}
+
+func TestValueControlCmdMissingTarget(t *testing.T) {
+	_, err := executeCmd("brightness", "-50")
+	if err == nil || !strings.Contains(err.Error(), "a target is required") {
+		t.Fatalf("expected missing target error, got %v", err)
+	}
+
+	_, err = executeCmd("brightness")
+	if err == nil || !strings.Contains(err.Error(), "requires at least 1 arg(s)") {
+		t.Fatalf("expected args error, got %v", err)
+	}
+}
+
+func TestParseColorTemp(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   string
+		errMsg string
+	}{
+		{"370", "370", ""},
+		{"2700K", "370", ""},
+		{"6500k", "154", ""},
+		{"Warm", "370", ""},
+		{"neutral", "250", ""},
+		{"cool", "200", ""},
+		{"daylight", "154", ""},
+		{"hotK", "", `invalid color temperature "hotK"`},
+		{"0K", "", `invalid color temperature "0K"`},
+	}
+	for _, tt := range tests {
+		got, err := parseColorTemp(tt.value)
+		if tt.errMsg != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("%q: expected error containing %q, got %v", tt.value, tt.errMsg, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q: expected %q, got %q (%v)", tt.value, tt.want, got, err)
+		}
+	}
+}
+
+func TestMiredsToKelvin(t *testing.T) {
+	if k := miredsToKelvin(370); k != 2703 {
+		t.Errorf("expected 2703, got %d", k)
+	}
+	if k := miredsToKelvin(0); k != 0 {
+		t.Errorf("expected 0, got %d", k)
+	}
+}
+
+func TestTempCmdKelvin(t *testing.T) {
+	var paths []string
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	})
+
+	outputFormat = display.FormatTable
+	for _, args := range [][]string{
+		{"temp", "2700K", "Lamp"},
+		{"temp", "daylight", "Lamp"},
+		{"temp", "--force", "1800K", "Lamp"},
+	} {
+		if _, err := executeCmd(args...); err != nil {
+			t.Fatalf("%v: unexpected error: %v", args, err)
+		}
+	}
+	want := []string{"/temp/370/Lamp", "/temp/154/Lamp", "/temp/556/Lamp"}
+	if strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Errorf("expected %v, got %v", want, paths)
+	}
+
+	_, err := executeCmd("temp", "1800K", "Lamp")
+	if err == nil || !strings.Contains(err.Error(), "2000-7150K") {
+		t.Errorf("expected range error in Kelvin, got %v", err)
+	}
+	if _, err := executeCmd("temp", "hotK", "Lamp"); err == nil {
+		t.Error("expected error for invalid Kelvin value")
+	}
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+	"github.com/spf13/cobra"
+)
+
+// exitWaitTimeout matches the exit status of timeout(1) so shell scripts can
+// tell a timeout apart from a connection or usage error.
+const exitWaitTimeout = 124
+
+var (
+	waitUntil    []string
+	waitTimeout  time.Duration
+	waitInterval time.Duration
+)
+
+var waitCmd = &cobra.Command{
+	Use:   "wait <target> [condition...]",
+	Short: "Block until a device reaches a state",
+	Long: "Poll a device, room or group until every condition holds for every device.\n" +
+		"Conditions compare a state property with a value, e.g. 'on=false',\n" +
+		"'temperature>=21' or 'position<10'. Supported operators: = != > >= < <=.\n" +
+		"They can follow the target or be given with --until.\n" +
+		fmt.Sprintf("Exits with status %d if --max-wait elapses first. --timeout still limits\n", exitWaitTimeout) +
+		"each request, as for every other command.",
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeTarget(completeAllTargets),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var target, conds []string
+		for _, a := range args {
+			if strings.ContainsAny(a, "=<>") {
+				conds = append(conds, a)
+			} else {
+				target = append(target, a)
+			}
+		}
+		conds = append(conds, waitUntil...)
+		if len(conds) == 0 {
+			return fmt.Errorf("at least one condition is required, e.g. on=false or --until on=false")
+		}
+		if len(target) == 0 {
+			return fmt.Errorf("a target is required")
+		}
+		if waitInterval <= 0 {
+			return fmt.Errorf("interval must be positive")
+		}
+		preds := make([]predicate, len(conds))
+		for i, expr := range conds {
+			p, err := parsePredicate(expr)
+			if err != nil {
+				return err
+			}
+			preds[i] = p
+		}
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		return runWait(cmd.Context(), c.Live(), strings.Join(target, " "), preds)
+	},
+}
+
+type predicate struct {
+	Key   string
+	Op    string
+	Value string
+}
+
+var predicateOps = []string{">=", "<=", "!=", "==", "=", ">", "<"}
+
+func parsePredicate(expr string) (predicate, error) {
+	for _, op := range predicateOps {
+		i := strings.Index(expr, op)
+		if i <= 0 {
+			continue
+		}
+		p := predicate{
+			Key:   strings.TrimSpace(expr[:i]),
+			Op:    op,
+			Value: strings.TrimSpace(expr[i+len(op):]),
+		}
+		if p.Op == "==" {
+			p.Op = "="
+		}
+		if p.Value == "" {
+			break
+		}
+		if p.Op != "=" && p.Op != "!=" {
+			if _, err := strconv.ParseFloat(p.Value, 64); err != nil {
+				return predicate{}, fmt.Errorf("invalid condition %q: %s needs a numeric value", expr, p.Op)
+			}
+		}
+		return p, nil
+	}
+	return predicate{}, fmt.Errorf("invalid condition %q: expected <property><op><value>, e.g. on=false or temperature>=21", expr)
+}
+
+func (p predicate) String() string {
+	return p.Key + p.Op + p.Value
+}
+
+// matches reports whether the device state satisfies the predicate. The
+// pseudo-property "reachable" is checked against DeviceInfo.Reachable.
+func (p predicate) matches(info client.DeviceInfo) bool {
+	var actual interface{}
+	if p.Key == "reachable" {
+		actual = info.Reachable
+	} else {
+		v, ok := info.State[p.Key]
+		if !ok {
+			return false
+		}
+		actual = v
+	}
+
+	switch v := actual.(type) {
+	case bool:
+		want, err := strconv.ParseBool(p.Value)
+		if err != nil {
+			return false
+		}
+		switch p.Op {
+		case "=":
+			return v == want
+		case "!=":
+			return v != want
+		}
+		return false
+	case string:
+		switch p.Op {
+		case "=":
+			return strings.EqualFold(v, p.Value)
+		case "!=":
+			return !strings.EqualFold(v, p.Value)
+		}
+		return false
+	}
+
+	want, err := strconv.ParseFloat(p.Value, 64)
+	if err != nil {
+		return false
+	}
+	got := toFloat(actual)
+	switch p.Op {
+	case "=":
+		return got == want
+	case "!=":
+		return got != want
+	case ">":
+		return got > want
+	case ">=":
+		return got >= want
+	case "<":
+		return got < want
+	}
+	return got <= want
+}
+
+func allMatch(infos []client.DeviceInfo, preds []predicate) bool {
+	if len(infos) == 0 {
+		return false
+	}
+	for _, info := range infos {
+		for _, p := range preds {
+			if !p.matches(info) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func runWait(parent context.Context, c *client.Client, target string, preds []predicate) error {
+	ctx := parent
+	if waitTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, waitTimeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(waitInterval)
+	defer ticker.Stop()
+
+	for {
+		infos, err := c.GetInfoContext(ctx, target)
+		if err == nil && allMatch(infos, preds) {
+			return render(display.Output{Data: infos, Table: multiInfoTable(infos), Text: "condition met\n"})
+		}
+		if err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			if parent.Err() != nil {
+				return parent.Err()
+			}
+			conds := make([]string, len(preds))
+			for i, p := range preds {
+				conds[i] = p.String()
+			}
+			return &exitError{
+				code: exitWaitTimeout,
+				err:  fmt.Errorf("timed out after %s waiting for %s: %s", waitTimeout, target, strings.Join(conds, ", ")),
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+func init() {
+	waitCmd.Flags().StringArrayVar(&waitUntil, "until", nil, "Condition to wait for (repeatable, all must hold)")
+	waitCmd.Flags().DurationVar(&waitTimeout, "max-wait", 0, "Give up after this long (0 = wait forever)")
+	waitCmd.Flags().DurationVarP(&waitInterval, "interval", "n", time.Second, "Polling interval")
+	rootCmd.AddCommand(waitCmd)
+}
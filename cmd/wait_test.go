@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+)
+
+func resetWaitFlags() {
+	waitUntil = nil
+	waitTimeout = 0
+	waitInterval = time.Second
+	outputFormat = display.FormatTable
+}
+
+func TestWaitCmdMatches(t *testing.T) {
+	var calls int32
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			w.WriteHeader(500)
+			return
+		}
+		json.NewEncoder(w).Encode(client.DeviceInfo{
+			Name: "Thermostat", Reachable: true,
+			State: map[string]interface{}{"on": true, "temperature": float64(18 + n)},
+		})
+	})
+	defer resetWaitFlags()
+
+	_, err := executeCmd("wait", "Hallway/Thermostat", "--until", "temperature>=21", "--until", "on=true", "-n", "1ms", "--max-wait", "5s")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 polls, got %d", calls)
+	}
+}
+
+func TestWaitCmdMatchesJSON(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(client.DeviceInfo{Name: "Door", Reachable: true, State: map[string]interface{}{"closed": true}})
+	})
+	defer resetWaitFlags()
+
+	_, err := executeCmd("wait", "--json", "Garage/Door", "--until", "closed=true")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWaitCmdPositionalCondition(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/info/Garage Door" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(client.DeviceInfo{Name: "Garage Door", Reachable: true, State: map[string]interface{}{"closed": true, "position": float64(0)}})
+	})
+	defer resetWaitFlags()
+
+	_, err := executeCmd("wait", "Garage", "Door", "closed=true", "--until", "position<10")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWaitCmdTimeout(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(client.DeviceInfo{Name: "Door", Reachable: true, State: map[string]interface{}{"closed": false}})
+	})
+	defer resetWaitFlags()
+
+	_, err := executeCmd("wait", "Garage/Door", "--until", "closed=true", "-n", "5ms", "--max-wait", "30ms")
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.code != exitWaitTimeout {
+		t.Fatalf("expected timeout exit error, got %v", err)
+	}
+}
+
+func TestWaitCmdRequestTimeout(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		json.NewEncoder(w).Encode(client.DeviceInfo{Name: "Door", Reachable: true, State: map[string]interface{}{"closed": true}})
+	})
+	defer resetWaitFlags()
+	defer func() { timeout = client.DefaultTimeout }()
+
+	// --timeout is the per-request limit, so every poll fails until --max-wait
+	_, err := executeCmd("wait", "Garage/Door", "closed=true", "-n", "5ms", "--timeout", "10ms", "--max-wait", "150ms")
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.code != exitWaitTimeout {
+		t.Fatalf("expected timeout exit error, got %v", err)
+	}
+	if timeout != 10*time.Millisecond {
+		t.Errorf("expected the root --timeout to be set, got %s", timeout)
+	}
+}
+
+func TestWaitCmdCanceled(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(client.DeviceInfo{Name: "Door", State: map[string]interface{}{"closed": false}})
+	})
+	defer resetWaitFlags()
+	waitInterval = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	preds := []predicate{{Key: "closed", Op: "=", Value: "true"}}
+	if err := runWait(ctx, testClient(t), "Door", preds); err != context.DeadlineExceeded {
+		t.Fatalf("expected parent context error, got %v", err)
+	}
+}
+
+func TestWaitCmdArgErrors(t *testing.T) {
+	defer resetWaitFlags()
+
+	cases := [][]string{
+		{"wait", "Lamp"},
+		{"wait", "Lamp", "--until", "on=true", "-n", "0s"},
+		{"wait", "Lamp", "--until", "nonsense"},
+		{"wait", "on=true"},
+	}
+	for _, args := range cases {
+		resetWaitFlags()
+		if _, err := executeCmd(args...); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+}
+
+func TestParsePredicate(t *testing.T) {
+	cases := []struct {
+		expr string
+		want predicate
+	}{
+		{"on=false", predicate{"on", "=", "false"}},
+		{"on == true", predicate{"on", "=", "true"}},
+		{"temperature>=21", predicate{"temperature", ">=", "21"}},
+		{"position<=10", predicate{"position", "<=", "10"}},
+		{"mode!=auto", predicate{"mode", "!=", "auto"}},
+		{"brightness>50", predicate{"brightness", ">", "50"}},
+		{"humidity<40.5", predicate{"humidity", "<", "40.5"}},
+	}
+	for _, tc := range cases {
+		got, err := parsePredicate(tc.expr)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.expr, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: expected %+v, got %+v", tc.expr, tc.want, got)
+		}
+	}
+
+	for _, expr := range []string{"on", "=true", "on=", "temperature>=warm"} {
+		if _, err := parsePredicate(expr); err == nil {
+			t.Errorf("%q: expected error", expr)
+		}
+	}
+}
+
+func TestPredicateMatches(t *testing.T) {
+	info := client.DeviceInfo{
+		Reachable: true,
+		State: map[string]interface{}{
+			"on":          false,
+			"temperature": float64(21),
+			"mode":        "Heat",
+		},
+	}
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{"on=false", true},
+		{"on!=false", false},
+		{"on=maybe", false},
+		{"on>1", false},
+		{"reachable=true", true},
+		{"mode=heat", true},
+		{"mode!=heat", false},
+		{"mode>1", false},
+		{"temperature=21", true},
+		{"temperature!=21", false},
+		{"temperature>21", false},
+		{"temperature>=21", true},
+		{"temperature<22", true},
+		{"temperature<=20", false},
+		{"temperature=warm", false},
+		{"missing=true", false},
+	}
+	for _, tc := range cases {
+		p, err := parsePredicate(tc.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.expr, err)
+		}
+		if got := p.matches(info); got != tc.want {
+			t.Errorf("%q: expected %v, got %v", tc.expr, tc.want, got)
+		}
+	}
+}
+
+func TestAllMatchEmpty(t *testing.T) {
+	if allMatch(nil, []predicate{{"on", "=", "true"}}) {
+		t.Error("expected no match for empty device list")
+	}
+}
+
+func TestExecuteExitCode(t *testing.T) {
+	var exitCode int
+	original := osExit
+	osExit = func(code int) { exitCode = code }
+	defer func() { osExit = original }()
+	defer resetWaitFlags()
+
+	rootCmd.SetArgs([]string{"wait", "Lamp", "--until", "on=true", "--max-wait", "1ms", "-n", "1ms"})
+	t.Setenv("HOME", t.TempDir())
+	Execute()
+
+	if exitCode != exitWaitTimeout {
+		t.Errorf("expected exit code %d, got %d", exitWaitTimeout, exitCode)
+	}
+}
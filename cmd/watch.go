@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchInterval time.Duration
+	watchCount    int
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [target]",
+	Short: "Watch device state and highlight changes",
+	Long: "Poll device state on an interval and redraw it in place, highlighting values\n" +
+		"that changed since the previous poll. Without a target, all devices are watched.\n" +
+		"With --json or --output ndjson, newline-delimited change events are written instead.",
+	ValidArgsFunction: completeTarget(completeAllTargets),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if watchInterval <= 0 {
+			return fmt.Errorf("interval must be positive")
+		}
+		switch outputFormat {
+		case display.FormatTable, display.FormatJSON, display.FormatNDJSON:
+		default:
+			return fmt.Errorf("watch does not support --output %s (use table, json or ndjson)", outputFormat)
+		}
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		return runWatch(cmd.Context(), c.Live(), strings.Join(args, " "))
+	},
+}
+
+type watchDevice struct {
+	Key   string
+	Name  string
+	Props map[string]interface{}
+}
+
+type changeEvent struct {
+	Device    string      `json:"device"`
+	Property  string      `json:"property"`
+	Old       interface{} `json:"old"`
+	New       interface{} `json:"new"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+func runWatch(ctx context.Context, c *client.Client, target string) error {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	var prev []watchDevice
+	for tick := 1; ; tick++ {
+		cur, err := fetchWatchSnapshot(ctx, c, target)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		} else {
+			now := time.Now()
+			var events []changeEvent
+			if prev != nil {
+				events = diffSnapshots(prev, cur, now)
+			}
+			if outputFormat != display.FormatTable {
+				enc := json.NewEncoder(os.Stdout)
+				for _, ev := range events {
+					enc.Encode(ev)
+				}
+			} else {
+				if display.IsTerminal(os.Stdout) {
+					fmt.Print("\033[H\033[2J")
+				}
+				fmt.Printf("Every %s: itsyhome watch %s    %s\n\n", watchInterval, target, now.Format("15:04:05"))
+				fmt.Print(renderWatchTable(cur, events, target == "").Render())
+			}
+			prev = cur
+		}
+
+		if watchCount > 0 && tick >= watchCount {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func fetchWatchSnapshot(ctx context.Context, c *client.Client, target string) ([]watchDevice, error) {
+	if target == "" {
+		devices, err := c.ListDevicesContext(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		snap := make([]watchDevice, len(devices))
+		for i, d := range devices {
+			snap[i] = watchDevice{
+				Key:   deviceKey(d.Room, d.Name),
+				Name:  d.Name,
+				Props: map[string]interface{}{"type": d.Type, "room": d.Room, "reachable": d.Reachable},
+			}
+		}
+		return snap, nil
+	}
+
+	infos, err := c.GetInfoContext(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	snap := make([]watchDevice, len(infos))
+	for i, info := range infos {
+		props := map[string]interface{}{"reachable": info.Reachable}
+		for k, v := range info.State {
+			props[k] = v
+		}
+		snap[i] = watchDevice{Key: deviceKey(info.Room, info.Name), Name: info.Name, Props: props}
+	}
+	return snap, nil
+}
+
+func deviceKey(room, name string) string {
+	if room == "" {
+		return name
+	}
+	return room + "/" + name
+}
+
+// diffSnapshots returns one event per property whose value differs between
+// the two snapshots. Devices or properties that appear or disappear are
+// reported with a nil old or new value.
+func diffSnapshots(prev, cur []watchDevice, now time.Time) []changeEvent {
+	before := map[string]map[string]interface{}{}
+	for _, d := range prev {
+		before[d.Key] = d.Props
+	}
+	after := map[string]map[string]interface{}{}
+	for _, d := range cur {
+		after[d.Key] = d.Props
+	}
+
+	keys := make([]string, 0, len(after))
+	for k := range after {
+		keys = append(keys, k)
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var events []changeEvent
+	for _, key := range keys {
+		oldProps, newProps := before[key], after[key]
+		names := make([]string, 0, len(newProps))
+		for p := range newProps {
+			names = append(names, p)
+		}
+		for p := range oldProps {
+			if _, ok := newProps[p]; !ok {
+				names = append(names, p)
+			}
+		}
+		sort.Strings(names)
+
+		for _, p := range names {
+			oldVal, hadOld := oldProps[p]
+			newVal, hasNew := newProps[p]
+			if hadOld && hasNew && fmt.Sprint(oldVal) == fmt.Sprint(newVal) {
+				continue
+			}
+			events = append(events, changeEvent{Device: key, Property: p, Old: oldVal, New: newVal, Timestamp: now})
+		}
+	}
+	return events
+}
+
+func renderWatchTable(snap []watchDevice, events []changeEvent, listMode bool) *display.Table {
+	changed := map[string]bool{}
+	for _, ev := range events {
+		changed[ev.Device+"\x00"+ev.Property] = true
+	}
+	isChanged := func(key, prop string) bool { return changed[key+"\x00"+prop] }
+
+	if listMode {
+		tbl := display.NewTable("Device", "Type", "Room", "Status")
+		for i, d := range snap {
+			status := "ok"
+			if b, _ := d.Props["reachable"].(bool); !b {
+				status = "unreachable"
+			}
+			tbl.AddRow(d.Name, fmt.Sprint(d.Props["type"]), fmt.Sprint(d.Props["room"]), status)
+			if isChanged(d.Key, "reachable") {
+				tbl.Highlight(i, 3)
+			}
+		}
+		return tbl
+	}
+
+	tbl := display.NewTable("Device", "Property", "Value")
+	row := 0
+	for _, d := range snap {
+		props := make([]string, 0, len(d.Props))
+		for p := range d.Props {
+			props = append(props, p)
+		}
+		sort.Strings(props)
+		for _, p := range props {
+			tbl.AddRow(d.Key, p, fmt.Sprintf("%v", d.Props[p]))
+			if isChanged(d.Key, p) {
+				tbl.Highlight(row, 2)
+			}
+			row++
+		}
+	}
+	return tbl
+}
+
+func init() {
+	watchCmd.Flags().DurationVarP(&watchInterval, "interval", "n", 2*time.Second, "Polling interval")
+	watchCmd.Flags().IntVar(&watchCount, "count", 0, "Stop after this many polls (0 = until interrupted)")
+	rootCmd.AddCommand(watchCmd)
+}
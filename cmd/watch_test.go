@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nickustinov/itsyhome-cli/internal/client"
+	"github.com/nickustinov/itsyhome-cli/internal/display"
+)
+
+// captureStdout runs fn and returns everything it wrote to os.Stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	return captureFile(t, &os.Stdout, fn)
+}
+
+// captureStderr runs fn and returns everything it wrote to os.Stderr.
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	return captureFile(t, &os.Stderr, fn)
+}
+
+func captureFile(t *testing.T, f **os.File, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	orig := *f
+	*f = w
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	defer func() { *f = orig }()
+	fn()
+	w.Close()
+	return <-done
+}
+
+// fakeTerminal makes stdout and stderr count as terminals, with color on,
+// for the rest of the test.
+func fakeTerminal(t *testing.T) {
+	t.Helper()
+	t.Setenv("NO_COLOR", "")
+	isTerminal := display.IsTerminal
+	display.IsTerminal = func(*os.File) bool { return true }
+	t.Cleanup(func() { display.IsTerminal = isTerminal })
+}
+
+func resetWatchFlags() {
+	watchInterval = 2 * time.Second
+	watchCount = 0
+	outputFormat = display.FormatTable
+}
+
+func TestWatchCmdJSONEvents(t *testing.T) {
+	var calls int32
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		json.NewEncoder(w).Encode(client.DeviceInfo{
+			Name: "Lamp", Room: "Office", Reachable: true,
+			State: map[string]interface{}{"on": true, "brightness": float64(10 * n)},
+		})
+	})
+	defer resetWatchFlags()
+
+	var err error
+	out := captureStdout(t, func() {
+		_, err = executeCmd("watch", "--json", "--interval", "1ms", "--count", "3", "Office/Lamp")
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 events, got %d: %q", len(lines), out)
+	}
+	var ev changeEvent
+	if err := json.Unmarshal([]byte(lines[1]), &ev); err != nil {
+		t.Fatalf("invalid event: %v", err)
+	}
+	if ev.Device != "Office/Lamp" || ev.Property != "brightness" || ev.Old != float64(20) || ev.New != float64(30) {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+}
+
+func TestWatchCmdTable(t *testing.T) {
+	var calls int32
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			w.WriteHeader(500)
+			return
+		}
+		json.NewEncoder(w).Encode([]client.Device{
+			{Name: "Lamp", Type: "light", Room: "Office", Reachable: n%2 == 0},
+			{Name: "Fan", Type: "fan", Reachable: true},
+		})
+	})
+	defer resetWatchFlags()
+	fakeTerminal(t)
+
+	var err error
+	out := captureStdout(t, func() {
+		_, err = executeCmd("watch", "--interval", "1ms", "--count", "3")
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "\033[7munreachable\033[0m") || !strings.Contains(out, "\033[2J") {
+		t.Errorf("expected highlighted status change, got %q", out)
+	}
+}
+
+func TestWatchCmdTargetTable(t *testing.T) {
+	var calls int32
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		json.NewEncoder(w).Encode([]client.DeviceInfo{
+			{Name: "Lamp", Reachable: true, State: map[string]interface{}{"on": n > 1}},
+		})
+	})
+	defer resetWatchFlags()
+
+	var err error
+	out := captureStdout(t, func() {
+		_, err = executeCmd("watch", "--interval", "1ms", "--count", "2", "Office")
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(out, "\033") {
+		t.Errorf("expected no escape sequences outside a terminal, got %q", out)
+	}
+
+	fakeTerminal(t)
+	atomic.StoreInt32(&calls, 0)
+	out = captureStdout(t, func() {
+		_, err = executeCmd("watch", "--interval", "1ms", "--count", "2", "Office")
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "\033[7mtrue") {
+		t.Errorf("expected highlighted value change, got %q", out)
+	}
+}
+
+func TestWatchCmdInvalidInterval(t *testing.T) {
+	defer resetWatchFlags()
+
+	_, err := executeCmd("watch", "--interval", "0s")
+	if err == nil {
+		t.Fatal("expected error for zero interval")
+	}
+}
+
+func TestRunWatchCanceled(t *testing.T) {
+	setupTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]client.Device{{Name: "Lamp"}})
+	})
+	defer resetWatchFlags()
+	watchInterval = time.Hour
+	outputFormat = display.FormatJSON
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := runWatch(ctx, testClient(t), ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := runWatch(ctx, testClient(t), "Lamp"); err != nil {
+		t.Fatalf("unexpected error after cancel: %v", err)
+	}
+}
+
+func TestDiffSnapshotsAddedAndRemoved(t *testing.T) {
+	now := time.Now()
+	prev := []watchDevice{
+		{Key: "Old", Props: map[string]interface{}{"on": true}},
+		{Key: "Lamp", Props: map[string]interface{}{"on": true, "brightness": 50}},
+	}
+	cur := []watchDevice{
+		{Key: "Lamp", Props: map[string]interface{}{"on": true}},
+		{Key: "New", Props: map[string]interface{}{"on": false}},
+	}
+
+	events := diffSnapshots(prev, cur, now)
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d: %+v", len(events), events)
+	}
+	if events[0].Device != "Lamp" || events[0].Property != "brightness" || events[0].New != nil {
+		t.Errorf("expected removed brightness, got %+v", events[0])
+	}
+	if events[1].Device != "New" || events[1].Old != nil {
+		t.Errorf("expected added device, got %+v", events[1])
+	}
+	if events[2].Device != "Old" || events[2].New != nil {
+		t.Errorf("expected removed device, got %+v", events[2])
+	}
+}
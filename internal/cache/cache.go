@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Store keeps responses on disk, one file per key, so they can be reused by
+// later runs. The age of an entry is the modification time of its file.
+type Store struct {
+	dir string
+}
+
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+func (s *Store) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+// Get returns the data stored under key and how long ago it was stored.
+func (s *Store) Get(key string) ([]byte, time.Duration, bool) {
+	path := s.path(key)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, 0, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, false
+	}
+	return data, time.Since(info.ModTime()), true
+}
+
+// Put stores data under key, replacing any earlier entry. The data is
+// written to a temporary file first so readers never see half of it.
+func (s *Store) Put(key string, data []byte) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	path := s.path(key)
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Clear removes every entry.
+func (s *Store) Clear() error {
+	return os.RemoveAll(s.dir)
+}
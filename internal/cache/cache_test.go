@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPutGet(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "itsyhome"))
+
+	if _, _, ok := s.Get("http://mac:8423/list/rooms"); ok {
+		t.Fatal("expected a miss before Put")
+	}
+	if err := s.Put("http://mac:8423/list/rooms", []byte(`[{"name":"Office"}]`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, age, ok := s.Get("http://mac:8423/list/rooms")
+	if !ok || string(data) != `[{"name":"Office"}]` {
+		t.Fatalf("unexpected entry: %q, %v", data, ok)
+	}
+	if age < 0 || age > time.Minute {
+		t.Errorf("unexpected age: %s", age)
+	}
+	if _, _, ok := s.Get("http://other:8423/list/rooms"); ok {
+		t.Error("expected keys to be kept apart")
+	}
+
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(s.path("http://mac:8423/list/rooms"), old, old)
+	if _, age, _ := s.Get("http://mac:8423/list/rooms"); age < time.Hour {
+		t.Errorf("expected the age to come from the file, got %s", age)
+	}
+}
+
+func TestGetUnreadable(t *testing.T) {
+	s := New(t.TempDir())
+	os.Mkdir(s.path("key"), 0700)
+	if _, _, ok := s.Get("key"); ok {
+		t.Error("expected a miss for an unreadable entry")
+	}
+}
+
+func TestPutErrors(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(file, nil, 0600)
+	if err := New(filepath.Join(file, "itsyhome")).Put("key", nil); err == nil {
+		t.Error("expected error when the directory cannot be created")
+	}
+
+	s := New(t.TempDir())
+	os.Mkdir(s.path("key")+".tmp", 0700)
+	if err := s.Put("key", nil); err == nil {
+		t.Error("expected error when the temporary file cannot be written")
+	}
+
+	s = New(t.TempDir())
+	os.MkdirAll(filepath.Join(s.path("key"), "entry"), 0700)
+	if err := s.Put("key", nil); err == nil {
+		t.Error("expected error when the entry cannot be replaced")
+	}
+}
+
+func TestClear(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "itsyhome")
+	s := New(dir)
+	if s.Dir() != dir {
+		t.Errorf("expected %s, got %s", dir, s.Dir())
+	}
+	s.Put("key", []byte("data"))
+	if err := s.Clear(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, ok := s.Get("key"); ok {
+		t.Error("expected the entry to be gone")
+	}
+}
@@ -1,11 +1,14 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/nickustinov/itsyhome-cli/internal/config"
@@ -13,7 +16,20 @@ import (
 
 type Client struct {
 	baseURL    string
+	token      string
 	httpClient *http.Client
+	observe    func(path string, resp *ActionResponse, err error)
+	resolve    TargetResolver
+	cache      ListCache
+	cacheTTL   time.Duration
+	onStale    func(path string, age time.Duration)
+	offline    bool
+}
+
+// ListCache keeps the responses of the /list endpoints between runs.
+type ListCache interface {
+	Get(key string) (data []byte, age time.Duration, ok bool)
+	Put(key string, data []byte) error
 }
 
 type ActionResponse struct {
@@ -61,17 +77,169 @@ type DeviceInfo struct {
 	State     map[string]interface{} `json:"state,omitempty"`
 }
 
-func New(cfg config.Config) *Client {
-	return &Client{
-		baseURL: cfg.BaseURL(),
+const DefaultTimeout = 10 * time.Second
+
+type Option func(*Client)
+
+// WithTimeout sets the overall deadline for each request. Zero disables
+// the client-side timeout and leaves cancellation to the caller's context.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient.Timeout = d
+	}
+}
+
+// WithActionObserver calls fn after every DoAction with its outcome, e.g.
+// to collect the responses of a batch of commands.
+func WithActionObserver(fn func(path string, resp *ActionResponse, err error)) Option {
+	return func(c *Client) {
+		c.observe = fn
+	}
+}
+
+// WithListCache answers ListRooms, ListDevices, ListScenes and ListGroups
+// from cache while the stored response is younger than ttl, and stores every
+// response fetched from the server. A ttl of 0 always refreshes. When the
+// server cannot be reached, an older cached response is used instead.
+func WithListCache(cache ListCache, ttl time.Duration) Option {
+	return func(c *Client) {
+		c.cache = cache
+		c.cacheTTL = ttl
+	}
+}
+
+// WithStaleNotice calls fn whenever a list is answered from an expired
+// cache entry because the server could not be reached.
+func WithStaleNotice(fn func(path string, age time.Duration)) Option {
+	return func(c *Client) {
+		c.onStale = fn
+	}
+}
+
+// WithOffline never contacts the server. Lists are answered from the cache
+// whatever their age and every other request fails.
+func WithOffline() Option {
+	return func(c *Client) {
+		c.offline = true
+	}
+}
+
+// Live returns a copy of c for callers that poll for changes. It bypasses
+// the list cache and does not consult the TargetResolver, which would
+// otherwise list every room, device, group and scene on each poll.
+func (c *Client) Live() *Client {
+	live := *c
+	live.cache = nil
+	live.resolve = nil
+	return &live
+}
+
+// Fresh returns a copy of c that fetches lists from the server even when
+// the cache is recent, falling back to the cache only if the server cannot
+// be reached. It is for callers that show or filter on reachability.
+func (c *Client) Fresh() *Client {
+	fresh := *c
+	fresh.cacheTTL = 0
+	return &fresh
+}
+
+// TargetResolver is consulted when the server does not know the target of
+// action. It returns a target to retry with, or the error to report.
+type TargetResolver func(ctx context.Context, c *Client, action, target string, err error) (string, error)
+
+// WithTargetResolver sets the resolver used by Control, ControlValue and
+// GetInfoContext after a "not found" error. Clients from Live do not use it.
+func WithTargetResolver(fn TargetResolver) Option {
+	return func(c *Client) {
+		c.resolve = fn
+	}
+}
+
+// ConnectionError is returned when the server cannot be reached at all.
+type ConnectionError struct {
+	Err error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("connection failed: %s\nIs the Itsyhome app running with the server enabled?\nNote: webhook/CLI access requires an Itsyhome Pro subscription.", e.Err)
+}
+
+func (e *ConnectionError) Unwrap() error { return e.Err }
+
+// NotFoundError is returned when the server reports that a device, room,
+// group or scene does not exist.
+type NotFoundError struct {
+	Message string
+}
+
+func (e *NotFoundError) Error() string { return e.Message }
+
+// apiError turns an error message from the server into an error, using
+// NotFoundError for unknown targets.
+func apiError(msg string) error {
+	if strings.HasSuffix(msg, "not found") {
+		return &NotFoundError{Message: msg}
+	}
+	return errors.New(msg)
+}
+
+// withTarget calls fn with target and, if the server does not know it and a
+// resolver is set, once more with the target the resolver picks.
+func (c *Client) withTarget(ctx context.Context, action, target string, fn func(target string) error) error {
+	err := fn(target)
+	var nf *NotFoundError
+	if c.resolve == nil || !errors.As(err, &nf) {
+		return err
+	}
+	target, err = c.resolve(ctx, c, action, target, err)
+	if err != nil {
+		return err
+	}
+	return fn(target)
+}
+
+func New(cfg config.Config, opts ...Option) (*Client, error) {
+	baseURL := cfg.BaseURL()
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q: expected http://host:port or https://host:port", baseURL)
+	}
+
+	tc, err := tlsConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tc
+
+	c := &Client{
+		baseURL: baseURL,
+		token:   cfg.Token,
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout:   DefaultTimeout,
+			Transport: transport,
 		},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
 }
 
 func (c *Client) DoAction(path string) (*ActionResponse, error) {
-	body, err := c.get(path)
+	return c.DoActionContext(context.Background(), path)
+}
+
+func (c *Client) DoActionContext(ctx context.Context, path string) (*ActionResponse, error) {
+	resp, err := c.doAction(ctx, path)
+	if c.observe != nil {
+		c.observe(path, resp, err)
+	}
+	return resp, err
+}
+
+func (c *Client) doAction(ctx context.Context, path string) (*ActionResponse, error) {
+	body, err := c.get(ctx, path)
 	if err != nil {
 		return nil, err
 	}
@@ -82,14 +250,18 @@ func (c *Client) DoAction(path string) (*ActionResponse, error) {
 	}
 
 	if resp.Status == "error" {
-		return nil, fmt.Errorf("%s", resp.Message)
+		return nil, apiError(resp.Message)
 	}
 
 	return &resp, nil
 }
 
 func (c *Client) GetStatus() (*StatusResponse, error) {
-	body, err := c.get("/status")
+	return c.GetStatusContext(context.Background())
+}
+
+func (c *Client) GetStatusContext(ctx context.Context) (*StatusResponse, error) {
+	body, err := c.get(ctx, "/status")
 	if err != nil {
 		return nil, err
 	}
@@ -103,70 +275,75 @@ func (c *Client) GetStatus() (*StatusResponse, error) {
 }
 
 func (c *Client) ListRooms() ([]Room, error) {
-	body, err := c.get("/list/rooms")
-	if err != nil {
-		return nil, err
-	}
+	return c.ListRoomsContext(context.Background())
+}
 
+func (c *Client) ListRoomsContext(ctx context.Context) ([]Room, error) {
 	var rooms []Room
-	if err := json.Unmarshal(body, &rooms); err != nil {
-		return nil, fmt.Errorf("parse response: %w", err)
+	if err := c.list(ctx, "/list/rooms", &rooms); err != nil {
+		return nil, err
 	}
-
 	return rooms, nil
 }
 
 func (c *Client) ListDevices(room string) ([]Device, error) {
+	return c.ListDevicesContext(context.Background(), room)
+}
+
+func (c *Client) ListDevicesContext(ctx context.Context, room string) ([]Device, error) {
 	path := "/list/devices"
 	if room != "" {
 		path += "/" + url.PathEscape(room)
 	}
 
-	body, err := c.get(path)
-	if err != nil {
-		return nil, err
-	}
-
 	var devices []Device
-	if err := json.Unmarshal(body, &devices); err != nil {
-		return nil, fmt.Errorf("parse response: %w", err)
+	if err := c.list(ctx, path, &devices); err != nil {
+		return nil, err
 	}
-
 	return devices, nil
 }
 
 func (c *Client) ListScenes() ([]Scene, error) {
-	body, err := c.get("/list/scenes")
-	if err != nil {
-		return nil, err
-	}
+	return c.ListScenesContext(context.Background())
+}
 
+func (c *Client) ListScenesContext(ctx context.Context) ([]Scene, error) {
 	var scenes []Scene
-	if err := json.Unmarshal(body, &scenes); err != nil {
-		return nil, fmt.Errorf("parse response: %w", err)
+	if err := c.list(ctx, "/list/scenes", &scenes); err != nil {
+		return nil, err
 	}
-
 	return scenes, nil
 }
 
 func (c *Client) ListGroups() ([]Group, error) {
-	body, err := c.get("/list/groups")
-	if err != nil {
-		return nil, err
-	}
+	return c.ListGroupsContext(context.Background())
+}
 
+func (c *Client) ListGroupsContext(ctx context.Context) ([]Group, error) {
 	var groups []Group
-	if err := json.Unmarshal(body, &groups); err != nil {
-		return nil, fmt.Errorf("parse response: %w", err)
+	if err := c.list(ctx, "/list/groups", &groups); err != nil {
+		return nil, err
 	}
-
 	return groups, nil
 }
 
 func (c *Client) GetInfo(target string) ([]DeviceInfo, error) {
-	path := "/info/" + url.PathEscape(target)
+	return c.GetInfoContext(context.Background(), target)
+}
+
+func (c *Client) GetInfoContext(ctx context.Context, target string) ([]DeviceInfo, error) {
+	var infos []DeviceInfo
+	err := c.withTarget(ctx, "info", target, func(target string) (err error) {
+		infos, err = c.getInfo(ctx, target)
+		return err
+	})
+	return infos, err
+}
+
+func (c *Client) getInfo(ctx context.Context, target string) ([]DeviceInfo, error) {
+	path := "/info/" + TargetPath(target)
 
-	body, err := c.get(path)
+	body, err := c.get(ctx, path)
 	if err != nil {
 		return nil, err
 	}
@@ -174,7 +351,7 @@ func (c *Client) GetInfo(target string) ([]DeviceInfo, error) {
 	// Check for error response first
 	var errResp ActionResponse
 	if json.Unmarshal(body, &errResp) == nil && errResp.Status == "error" {
-		return nil, fmt.Errorf("%s", errResp.Message)
+		return nil, apiError(errResp.Message)
 	}
 
 	// Try array
@@ -192,10 +369,61 @@ func (c *Client) GetInfo(target string) ([]DeviceInfo, error) {
 	return []DeviceInfo{info}, nil
 }
 
-func (c *Client) get(path string) ([]byte, error) {
-	resp, err := c.httpClient.Get(c.baseURL + path)
+// list fetches a /list endpoint into v, going through the cache if there
+// is one. Only responses that parse are stored, and a cached response of
+// any age stands in when the server cannot be reached.
+func (c *Client) list(ctx context.Context, path string, v interface{}) error {
+	key := c.baseURL + path
+	if c.cache != nil {
+		if data, age, ok := c.cache.Get(key); ok && (c.offline || age < c.cacheTTL) && json.Unmarshal(data, v) == nil {
+			return nil
+		}
+	}
+	if c.offline {
+		return fmt.Errorf("offline: %s has not been cached yet", path)
+	}
+
+	body, err := c.get(ctx, path)
+	var connErr *ConnectionError
+	if errors.As(err, &connErr) && c.cache != nil {
+		if data, age, ok := c.cache.Get(key); ok && json.Unmarshal(data, v) == nil {
+			if c.onStale != nil {
+				c.onStale(path, age)
+			}
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("parse response: %w", err)
+	}
+	if c.cache != nil {
+		// A cache that cannot be written only costs a request next time
+		c.cache.Put(key, body)
+	}
+	return nil
+}
+
+func (c *Client) get(ctx context.Context, path string) ([]byte, error) {
+	if c.offline {
+		return nil, fmt.Errorf("offline: %s needs the server", path)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("connection failed: %w\nIs the Itsyhome app running with the server enabled?\nNote: webhook/CLI access requires an Itsyhome Pro subscription.", err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, &ConnectionError{Err: err}
 	}
 	defer resp.Body.Close()
 
@@ -204,6 +432,13 @@ func (c *Client) get(path string) ([]byte, error) {
 		return nil, fmt.Errorf("read response: %w", err)
 	}
 
+	if resp.StatusCode == 401 {
+		if c.token == "" {
+			return nil, fmt.Errorf("authentication required: set an API token with 'itsyhome config set --token' or $%s", config.TokenEnv)
+		}
+		return nil, fmt.Errorf("authentication failed: the server rejected the API token")
+	}
+
 	if resp.StatusCode == 403 {
 		return nil, fmt.Errorf("Itsyhome Pro required for webhook/CLI access")
 	}
@@ -211,7 +446,7 @@ func (c *Client) get(path string) ([]byte, error) {
 	if resp.StatusCode >= 400 {
 		var errResp ActionResponse
 		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Message != "" {
-			return nil, fmt.Errorf("%s", errResp.Message)
+			return nil, apiError(errResp.Message)
 		}
 		return nil, fmt.Errorf("server error: %d", resp.StatusCode)
 	}
@@ -1,11 +1,15 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/nickustinov/itsyhome-cli/internal/config"
 )
@@ -304,6 +308,20 @@ func TestGetInfo(t *testing.T) {
 	}
 }
 
+func TestGetInfoEscapesTarget(t *testing.T) {
+	srv, c := testServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/info/Kids%20%231/Lamp%3F" {
+			t.Errorf("unexpected escaped path: %s", r.URL.EscapedPath())
+		}
+		json.NewEncoder(w).Encode(DeviceInfo{Name: "Lamp?"})
+	})
+	defer srv.Close()
+
+	if _, err := c.GetInfo("Kids #1/Lamp?"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
 func TestGetInfoArray(t *testing.T) {
 	srv, c := testServer(func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode([]DeviceInfo{
@@ -394,7 +412,10 @@ func TestHTTP500NoBody(t *testing.T) {
 
 func TestNewClient(t *testing.T) {
 	cfg := config.Config{Host: "example.com", Port: 1234}
-	c := New(cfg)
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	if c.baseURL != "http://example.com:1234" {
 		t.Errorf("unexpected baseURL: %s", c.baseURL)
 	}
@@ -402,8 +423,11 @@ func TestNewClient(t *testing.T) {
 
 func TestConnectionRefused(t *testing.T) {
 	cfg := config.Config{Host: "localhost", Port: 1}
-	c := New(cfg)
-	_, err := c.GetStatus()
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = c.GetStatus()
 	if err == nil {
 		t.Fatal("expected connection error")
 	}
@@ -482,8 +506,386 @@ func TestGetReadBodyError(t *testing.T) {
 		},
 	}
 
-	_, err := c.get("/test")
+	_, err := c.get(context.Background(), "/test")
 	if err == nil {
 		t.Fatal("expected read error")
 	}
 }
+
+func TestNewClientDefaultTimeout(t *testing.T) {
+	c, err := New(config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.httpClient.Timeout != DefaultTimeout {
+		t.Errorf("expected %s, got %s", DefaultTimeout, c.httpClient.Timeout)
+	}
+}
+
+func TestNewClientWithTimeout(t *testing.T) {
+	c, err := New(config.DefaultConfig(), WithTimeout(3*time.Second))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.httpClient.Timeout != 3*time.Second {
+		t.Errorf("expected 3s, got %s", c.httpClient.Timeout)
+	}
+}
+
+func TestWithActionObserver(t *testing.T) {
+	srv, c := testServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/on/Unknown" {
+			json.NewEncoder(w).Encode(ActionResponse{Status: "error", Message: "device not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(ActionResponse{Status: "success"})
+	})
+	defer srv.Close()
+
+	var seen []string
+	WithActionObserver(func(path string, resp *ActionResponse, err error) {
+		if err != nil {
+			seen = append(seen, path+" "+err.Error())
+			return
+		}
+		seen = append(seen, path+" "+resp.Status)
+	})(c)
+
+	c.DoAction("/on/Lamp")
+	c.DoAction("/on/Unknown")
+	want := []string{"/on/Lamp success", "/on/Unknown device not found"}
+	if strings.Join(seen, "|") != strings.Join(want, "|") {
+		t.Errorf("expected %v, got %v", want, seen)
+	}
+}
+
+func TestGetInfoContext(t *testing.T) {
+	srv, c := testServer(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(DeviceInfo{Name: "Lamp", Type: "light", Reachable: true})
+	})
+	defer srv.Close()
+
+	infos, err := c.GetInfoContext(context.Background(), "Lamp")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(infos) != 1 || infos[0].Name != "Lamp" {
+		t.Errorf("unexpected infos: %+v", infos)
+	}
+}
+
+func TestContextCanceled(t *testing.T) {
+	srv, c := testServer(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ActionResponse{Status: "success"})
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := c.DoActionContext(ctx, "/toggle/Lamp")
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestContextDeadline(t *testing.T) {
+	release := make(chan struct{})
+	srv, c := testServer(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := c.GetStatusContext(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestGetBuildRequestError(t *testing.T) {
+	c := &Client{baseURL: "http://%zz", httpClient: http.DefaultClient}
+
+	_, err := c.ListRooms()
+	if err == nil {
+		t.Fatal("expected request build error")
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	srv, c := testServer(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer s3cret" {
+			t.Errorf("unexpected Authorization header: %q", got)
+		}
+		json.NewEncoder(w).Encode(StatusResponse{Rooms: 1})
+	})
+	defer srv.Close()
+	c.token = "s3cret"
+
+	if _, err := c.GetStatus(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNoTokenNoHeader(t *testing.T) {
+	srv, c := testServer(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Header["Authorization"]; ok {
+			t.Error("expected no Authorization header")
+		}
+		json.NewEncoder(w).Encode(StatusResponse{})
+	})
+	defer srv.Close()
+
+	if _, err := c.GetStatus(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNewClientToken(t *testing.T) {
+	c, err := New(config.Config{Host: "example.com", Port: 1, Token: "abc"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.token != "abc" {
+		t.Errorf("expected token abc, got %q", c.token)
+	}
+}
+
+func TestHTTP401(t *testing.T) {
+	srv, c := testServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(401)
+	})
+	defer srv.Close()
+
+	_, err := c.GetStatus()
+	if err == nil || !strings.HasPrefix(err.Error(), "authentication required") {
+		t.Errorf("expected missing token error, got %v", err)
+	}
+
+	c.token = "wrong"
+	_, err = c.GetStatus()
+	if err == nil || err.Error() != "authentication failed: the server rejected the API token" {
+		t.Errorf("expected rejected token error, got %v", err)
+	}
+}
+
+func TestNotFoundError(t *testing.T) {
+	srv, c := testServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/info/Attic":
+			w.WriteHeader(404)
+			json.NewEncoder(w).Encode(ActionResponse{Status: "error", Message: "room not found"})
+		case "/info/Lamp":
+			json.NewEncoder(w).Encode(ActionResponse{Status: "error", Message: "device not found"})
+		default:
+			json.NewEncoder(w).Encode(ActionResponse{Status: "error", Message: "device not responding"})
+		}
+	})
+	defer srv.Close()
+
+	var nf *NotFoundError
+	if _, err := c.GetInfo("Attic"); !errors.As(err, &nf) || err.Error() != "room not found" {
+		t.Errorf("expected NotFoundError, got %v", err)
+	}
+	if _, err := c.GetInfo("Lamp"); !errors.As(err, &nf) || err.Error() != "device not found" {
+		t.Errorf("expected NotFoundError, got %v", err)
+	}
+	if _, err := c.DoAction("/on/Fan"); errors.As(err, &nf) || err.Error() != "device not responding" {
+		t.Errorf("expected plain error, got %v", err)
+	}
+}
+
+func TestWithTargetResolver(t *testing.T) {
+	srv, c := testServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/on/Office/Lamp", "/brightness/40/Office/Lamp":
+			json.NewEncoder(w).Encode(ActionResponse{Status: "success"})
+		case "/info/Office/Lamp":
+			json.NewEncoder(w).Encode(DeviceInfo{Name: "Lamp", Room: "Office"})
+		case "/on/Broken":
+			json.NewEncoder(w).Encode(ActionResponse{Status: "error", Message: "device not responding"})
+		default:
+			json.NewEncoder(w).Encode(ActionResponse{Status: "error", Message: "device not found"})
+		}
+	})
+	defer srv.Close()
+
+	var calls []string
+	WithTargetResolver(func(ctx context.Context, rc *Client, action, target string, err error) (string, error) {
+		if rc != c {
+			t.Error("expected the resolver to get the client")
+		}
+		calls = append(calls, action+" "+target)
+		if target == "Nowhere" {
+			return "", fmt.Errorf("%w: no suggestions", err)
+		}
+		return "Office/Lamp", nil
+	})(c)
+
+	ctx := context.Background()
+	if resp, err := c.On(ctx, "Ofice/Lamp"); err != nil || resp.Status != "success" {
+		t.Errorf("expected retry to succeed, got %v, %v", resp, err)
+	}
+	if _, err := c.SetBrightness(ctx, "lamp", 40); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if infos, err := c.GetInfoContext(ctx, "Lmap"); err != nil || infos[0].Room != "Office" {
+		t.Errorf("expected retry to succeed, got %v, %v", infos, err)
+	}
+	if _, err := c.On(ctx, "Nowhere"); err == nil || err.Error() != "device not found: no suggestions" {
+		t.Errorf("expected resolver error, got %v", err)
+	}
+	if _, err := c.On(ctx, "Broken"); err == nil || err.Error() != "device not responding" {
+		t.Errorf("expected error without resolving, got %v", err)
+	}
+
+	want := "on Ofice/Lamp|brightness lamp|info Lmap|on Nowhere"
+	if got := strings.Join(calls, "|"); got != want {
+		t.Errorf("expected resolver calls %q, got %q", want, got)
+	}
+}
+
+type memCache struct {
+	data map[string][]byte
+	age  time.Duration
+}
+
+func (m *memCache) Get(key string) ([]byte, time.Duration, bool) {
+	d, ok := m.data[key]
+	return d, m.age, ok
+}
+
+func (m *memCache) Put(key string, data []byte) error {
+	m.data[key] = data
+	return nil
+}
+
+func TestListCache(t *testing.T) {
+	requests := 0
+	srv, c := testServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/list/scenes" {
+			w.Write([]byte("not json"))
+			return
+		}
+		json.NewEncoder(w).Encode([]Room{{Name: "Office"}})
+	})
+	defer srv.Close()
+
+	mc := &memCache{data: map[string][]byte{}}
+	WithListCache(mc, time.Minute)(c)
+
+	rooms, err := c.ListRooms()
+	if err != nil || len(rooms) != 1 || requests != 1 {
+		t.Fatalf("expected a fetch, got %v, %v after %d requests", rooms, err, requests)
+	}
+	if _, ok := mc.data[srv.URL+"/list/rooms"]; !ok {
+		t.Fatalf("expected the response to be stored, got %v", mc.data)
+	}
+
+	mc.data[srv.URL+"/list/rooms"] = []byte(`[{"name":"Cached"}]`)
+	rooms, _ = c.ListRooms()
+	if rooms[0].Name != "Cached" || requests != 1 {
+		t.Errorf("expected a cache hit, got %v after %d requests", rooms, requests)
+	}
+
+	mc.age = time.Hour
+	rooms, _ = c.ListRooms()
+	if rooms[0].Name != "Office" || requests != 2 {
+		t.Errorf("expected a stale entry to be refetched, got %v after %d requests", rooms, requests)
+	}
+
+	mc.age = 0
+	mc.data[srv.URL+"/list/rooms"] = []byte("corrupt")
+	rooms, _ = c.ListRooms()
+	if rooms[0].Name != "Office" || requests != 3 {
+		t.Errorf("expected a corrupt entry to be refetched, got %v after %d requests", rooms, requests)
+	}
+
+	if _, err := c.ListScenes(); err == nil {
+		t.Error("expected parse error")
+	}
+	if _, ok := mc.data[srv.URL+"/list/scenes"]; ok {
+		t.Error("expected an unparsable response not to be stored")
+	}
+
+	if _, err := c.Live().ListRooms(); err != nil || requests != 5 {
+		t.Errorf("expected Live to bypass the cache, got %v after %d requests", err, requests)
+	}
+
+	WithListCache(mc, 0)(c)
+	c.ListRooms()
+	if requests != 6 {
+		t.Errorf("expected a zero TTL to refresh, got %d requests", requests)
+	}
+}
+
+func TestOffline(t *testing.T) {
+	requests := 0
+	srv, c := testServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	})
+	defer srv.Close()
+
+	mc := &memCache{data: map[string][]byte{srv.URL + "/list/groups": []byte(`[{"name":"All"}]`)}, age: 24 * time.Hour}
+	WithListCache(mc, time.Minute)(c)
+	WithOffline()(c)
+
+	groups, err := c.ListGroups()
+	if err != nil || len(groups) != 1 || groups[0].Name != "All" {
+		t.Errorf("expected the cached groups, got %v, %v", groups, err)
+	}
+	if _, err := c.ListDevices("Office"); err == nil || err.Error() != "offline: /list/devices/Office has not been cached yet" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := c.GetStatus(); err == nil || err.Error() != "offline: /status needs the server" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("expected no requests, got %d", requests)
+	}
+}
+
+func TestListCacheFallback(t *testing.T) {
+	requests := 0
+	srv, c := testServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode([]Room{{Name: "Office"}})
+	})
+
+	mc := &memCache{data: map[string][]byte{}}
+	WithListCache(mc, time.Minute)(c)
+	if _, err := c.ListRooms(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.Fresh().ListRooms(); err != nil || requests != 2 {
+		t.Errorf("expected Fresh to refetch a recent entry, got %v after %d requests", err, requests)
+	}
+	srv.Close()
+
+	var notices []string
+	WithStaleNotice(func(path string, age time.Duration) {
+		notices = append(notices, fmt.Sprintf("%s %s", path, age))
+	})(c)
+	mc.age = time.Hour
+	rooms, err := c.ListRooms()
+	if err != nil || len(rooms) != 1 || rooms[0].Name != "Office" {
+		t.Errorf("expected the cached rooms, got %v, %v", rooms, err)
+	}
+	if len(notices) != 1 || notices[0] != "/list/rooms 1h0m0s" {
+		t.Errorf("expected one stale notice, got %v", notices)
+	}
+
+	var connErr *ConnectionError
+	if _, err := c.ListScenes(); !errors.As(err, &connErr) || !strings.HasPrefix(err.Error(), "connection failed: ") || connErr.Unwrap() == nil {
+		t.Errorf("expected a connection error without a cached entry, got %v", err)
+	}
+
+	WithStaleNotice(nil)(c)
+	if _, err := c.ListRooms(); err != nil {
+		t.Errorf("expected the cached rooms without a notice, got %v", err)
+	}
+}
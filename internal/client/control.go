@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// TargetPath escapes each segment of a target such as "Living Room/Lamp"
+// or "Office/group.All Lights", keeping the "/" separators the server uses
+// to split room, device and group names.
+func TargetPath(target string) string {
+	segments := strings.Split(target, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// ActionPath builds /<action>/<target>, or /<action>/<value>/<target> when
+// value is non-empty, with every component escaped.
+func ActionPath(action, value, target string) string {
+	path := "/" + url.PathEscape(action)
+	if value != "" {
+		path += "/" + url.PathEscape(value)
+	}
+	return path + "/" + TargetPath(target)
+}
+
+func (c *Client) Control(ctx context.Context, action, target string) (*ActionResponse, error) {
+	return c.ControlValue(ctx, action, "", target)
+}
+
+func (c *Client) ControlValue(ctx context.Context, action, value, target string) (*ActionResponse, error) {
+	var resp *ActionResponse
+	err := c.withTarget(ctx, action, target, func(target string) (err error) {
+		resp, err = c.DoActionContext(ctx, ActionPath(action, value, target))
+		return err
+	})
+	return resp, err
+}
+
+func (c *Client) Toggle(ctx context.Context, target string) (*ActionResponse, error) {
+	return c.Control(ctx, "toggle", target)
+}
+
+func (c *Client) On(ctx context.Context, target string) (*ActionResponse, error) {
+	return c.Control(ctx, "on", target)
+}
+
+func (c *Client) Off(ctx context.Context, target string) (*ActionResponse, error) {
+	return c.Control(ctx, "off", target)
+}
+
+func (c *Client) Lock(ctx context.Context, target string) (*ActionResponse, error) {
+	return c.Control(ctx, "lock", target)
+}
+
+func (c *Client) Unlock(ctx context.Context, target string) (*ActionResponse, error) {
+	return c.Control(ctx, "unlock", target)
+}
+
+func (c *Client) Open(ctx context.Context, target string) (*ActionResponse, error) {
+	return c.Control(ctx, "open", target)
+}
+
+func (c *Client) Close(ctx context.Context, target string) (*ActionResponse, error) {
+	return c.Control(ctx, "close", target)
+}
+
+func (c *Client) ActivateScene(ctx context.Context, name string) (*ActionResponse, error) {
+	return c.Control(ctx, "scene", name)
+}
+
+func (c *Client) SetBrightness(ctx context.Context, target string, percent int) (*ActionResponse, error) {
+	return c.ControlValue(ctx, "brightness", strconv.Itoa(percent), target)
+}
+
+func (c *Client) SetPosition(ctx context.Context, target string, percent int) (*ActionResponse, error) {
+	return c.ControlValue(ctx, "position", strconv.Itoa(percent), target)
+}
+
+func (c *Client) SetSpeed(ctx context.Context, target string, percent int) (*ActionResponse, error) {
+	return c.ControlValue(ctx, "speed", strconv.Itoa(percent), target)
+}
+
+// SetColorTemp sets the color temperature in mireds.
+func (c *Client) SetColorTemp(ctx context.Context, target string, mireds int) (*ActionResponse, error) {
+	return c.ControlValue(ctx, "temp", strconv.Itoa(mireds), target)
+}
+
+// SetColor sets the color from a hex string such as "FF6600".
+func (c *Client) SetColor(ctx context.Context, target, hex string) (*ActionResponse, error) {
+	return c.ControlValue(ctx, "color", hex, target)
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestTargetPath(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"Lamp", "Lamp"},
+		{"Living Room/Lamp", "Living%20Room/Lamp"},
+		{"Office/group.All Lights", "Office/group.All%20Lights"},
+		{"Kids #1/Lamp?", "Kids%20%231/Lamp%3F"},
+		{"100% Bulb", "100%25%20Bulb"},
+		{"Küche/Licht", "K%C3%BCche/Licht"},
+	}
+	for _, tt := range tests {
+		if got := TargetPath(tt.target); got != tt.want {
+			t.Errorf("TargetPath(%q) = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestActionPath(t *testing.T) {
+	if got := ActionPath("toggle", "", "Office/Lamp"); got != "/toggle/Office/Lamp" {
+		t.Errorf("unexpected path: %s", got)
+	}
+	if got := ActionPath("color", "#FF6600", "Living Room/Lamp"); got != "/color/%23FF6600/Living%20Room/Lamp" {
+		t.Errorf("unexpected path: %s", got)
+	}
+}
+
+func TestControlMethods(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		call func(c *Client) (*ActionResponse, error)
+		path string
+	}{
+		{"toggle", func(c *Client) (*ActionResponse, error) { return c.Toggle(ctx, "Office/Lamp") }, "/toggle/Office/Lamp"},
+		{"on", func(c *Client) (*ActionResponse, error) { return c.On(ctx, "Kids #1/Lamp") }, "/on/Kids #1/Lamp"},
+		{"off", func(c *Client) (*ActionResponse, error) { return c.Off(ctx, "group.Downstairs") }, "/off/group.Downstairs"},
+		{"lock", func(c *Client) (*ActionResponse, error) { return c.Lock(ctx, "Front Door") }, "/lock/Front Door"},
+		{"unlock", func(c *Client) (*ActionResponse, error) { return c.Unlock(ctx, "Front Door") }, "/unlock/Front Door"},
+		{"open", func(c *Client) (*ActionResponse, error) { return c.Open(ctx, "Garage?") }, "/open/Garage?"},
+		{"close", func(c *Client) (*ActionResponse, error) { return c.Close(ctx, "Blinds") }, "/close/Blinds"},
+		{"scene", func(c *Client) (*ActionResponse, error) { return c.ActivateScene(ctx, "Good night") }, "/scene/Good night"},
+		{"brightness", func(c *Client) (*ActionResponse, error) { return c.SetBrightness(ctx, "Küche/Licht", 50) }, "/brightness/50/Küche/Licht"},
+		{"position", func(c *Client) (*ActionResponse, error) { return c.SetPosition(ctx, "Blinds", 75) }, "/position/75/Blinds"},
+		{"speed", func(c *Client) (*ActionResponse, error) { return c.SetSpeed(ctx, "Fan", 30) }, "/speed/30/Fan"},
+		{"temp", func(c *Client) (*ActionResponse, error) { return c.SetColorTemp(ctx, "Lamp", 370) }, "/temp/370/Lamp"},
+		{"color", func(c *Client) (*ActionResponse, error) { return c.SetColor(ctx, "100% Bulb", "FF6600") }, "/color/FF6600/100% Bulb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, c := testServer(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.path {
+					t.Errorf("expected %s, got %s", tt.path, r.URL.Path)
+				}
+				json.NewEncoder(w).Encode(ActionResponse{Status: "success"})
+			})
+			defer srv.Close()
+
+			resp, err := tt.call(c)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Status != "success" {
+				t.Errorf("expected success, got %s", resp.Status)
+			}
+		})
+	}
+}
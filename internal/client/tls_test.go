@@ -0,0 +1,151 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nickustinov/itsyhome-cli/internal/config"
+)
+
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+// clientCertFiles generates a self-signed client certificate and returns the
+// paths of its PEM certificate and key.
+func clientCertFiles(t *testing.T) (string, string) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "itsyhome-cli"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return writePEM(t, "client.pem", "CERTIFICATE", der), writePEM(t, "client-key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(StatusResponse{Rooms: 1})
+}
+
+func TestNewInvalidURL(t *testing.T) {
+	for _, cfg := range []config.Config{
+		{URL: "ftp://mac.local"},
+		{URL: "http://"},
+		{URL: "http://%zz"},
+		{Scheme: "gopher", Host: "mac.local", Port: 1},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("%+v: expected invalid URL error", cfg)
+		}
+	}
+}
+
+func TestHTTPSCustomCA(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(statusHandler))
+	defer srv.Close()
+
+	c, err := New(config.Config{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.GetStatus(); err == nil {
+		t.Fatal("expected certificate error without CA bundle")
+	}
+
+	ca := writePEM(t, "ca.pem", "CERTIFICATE", srv.Certificate().Raw)
+	c, err = New(config.Config{URL: srv.URL, CACert: ca})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.GetStatus(); err != nil {
+		t.Fatalf("unexpected error with CA bundle: %v", err)
+	}
+}
+
+func TestHTTPSInsecureSkipVerify(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(statusHandler))
+	defer srv.Close()
+
+	on := true
+	c, err := New(config.Config{URL: srv.URL, InsecureSkipVerify: &on})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.GetStatus(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestHTTPSClientCertificate(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 || r.TLS.PeerCertificates[0].Subject.CommonName != "itsyhome-cli" {
+			t.Error("expected client certificate")
+		}
+		statusHandler(w, r)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	certFile, keyFile := clientCertFiles(t)
+	ca := writePEM(t, "ca.pem", "CERTIFICATE", srv.Certificate().Raw)
+	c, err := New(config.Config{URL: srv.URL, CACert: ca, ClientCert: certFile, ClientKey: keyFile})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.GetStatus(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	certFile, keyFile := clientCertFiles(t)
+	notPEM := filepath.Join(t.TempDir(), "empty.pem")
+	os.WriteFile(notPEM, []byte("nothing here"), 0600)
+
+	cases := []struct {
+		cfg  config.Config
+		want string
+	}{
+		{config.Config{CACert: filepath.Join(t.TempDir(), "missing.pem")}, "read CA bundle"},
+		{config.Config{CACert: notPEM}, "no certificates found"},
+		{config.Config{ClientCert: certFile}, "must be set together"},
+		{config.Config{ClientKey: keyFile}, "must be set together"},
+		{config.Config{ClientCert: certFile, ClientKey: certFile}, "load client certificate"},
+	}
+	for _, tc := range cases {
+		tc.cfg.Host, tc.cfg.Port = "localhost", 8423
+		_, err := New(tc.cfg)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%+v: expected %q error, got %v", tc.cfg, tc.want, err)
+		}
+	}
+}
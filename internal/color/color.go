@@ -0,0 +1,161 @@
+package color
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type RGB struct {
+	R, G, B uint8
+}
+
+// Hex returns the color as six uppercase hex digits without a leading #,
+// the form the server's /color endpoint expects.
+func (c RGB) Hex() string {
+	return fmt.Sprintf("%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// Parse accepts CSS named colors, #RGB, #RRGGBB (the # is optional for six
+// digits), rgb(r, g, b) and hsl(h, s%, l%).
+func Parse(s string) (RGB, error) {
+	in := strings.ToLower(strings.TrimSpace(s))
+	if c, ok := names[in]; ok {
+		return c, nil
+	}
+
+	var (
+		c   RGB
+		err error
+	)
+	switch {
+	case strings.HasPrefix(in, "rgb(") && strings.HasSuffix(in, ")"):
+		c, err = parseRGB(in[4 : len(in)-1])
+	case strings.HasPrefix(in, "hsl(") && strings.HasSuffix(in, ")"):
+		c, err = parseHSL(in[4 : len(in)-1])
+	case strings.HasPrefix(in, "#"):
+		c, err = parseHex(in[1:], true)
+	default:
+		c, err = parseHex(in, false)
+	}
+	if err != nil {
+		return RGB{}, fmt.Errorf("invalid color %q: %w", s, err)
+	}
+	return c, nil
+}
+
+func parseHex(s string, short bool) (RGB, error) {
+	if short && len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return RGB{}, fmt.Errorf("expected a CSS color name, #RGB, #RRGGBB, rgb() or hsl()")
+	}
+	n, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return RGB{}, fmt.Errorf("%q is not a hex value", s)
+	}
+	return RGB{uint8(n >> 16), uint8(n >> 8), uint8(n)}, nil
+}
+
+// splitArgs splits function arguments separated by commas or whitespace.
+func splitArgs(s string, n int) ([]string, error) {
+	args := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+	if len(args) != n {
+		return nil, fmt.Errorf("expected %d values, got %d", n, len(args))
+	}
+	return args, nil
+}
+
+// parseNumber parses a number that may end in suffix (e.g. "%" or "deg").
+func parseNumber(s, suffix string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, suffix), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	return v, nil
+}
+
+func parseRGB(s string) (RGB, error) {
+	args, err := splitArgs(s, 3)
+	if err != nil {
+		return RGB{}, err
+	}
+	var ch [3]uint8
+	for i, a := range args {
+		v, err := parseNumber(a, "%")
+		if err != nil {
+			return RGB{}, err
+		}
+		if strings.HasSuffix(a, "%") {
+			v = v * 255 / 100
+		}
+		if v < 0 || v > 255 {
+			return RGB{}, fmt.Errorf("%s is out of range 0-255", a)
+		}
+		ch[i] = uint8(math.Round(v))
+	}
+	return RGB{ch[0], ch[1], ch[2]}, nil
+}
+
+func parseHSL(s string) (RGB, error) {
+	args, err := splitArgs(s, 3)
+	if err != nil {
+		return RGB{}, err
+	}
+	h, err := parseNumber(args[0], "deg")
+	if err != nil {
+		return RGB{}, err
+	}
+	var sl [2]float64
+	for i, a := range args[1:] {
+		v, err := parseNumber(a, "%")
+		if err != nil {
+			return RGB{}, err
+		}
+		if v < 0 || v > 100 {
+			return RGB{}, fmt.Errorf("%s is out of range 0-100%%", a)
+		}
+		sl[i] = v / 100
+	}
+	return FromHSL(h, sl[0], sl[1]), nil
+}
+
+// FromHSL converts hue in degrees and saturation and lightness in 0-1.
+func FromHSL(h, s, l float64) RGB {
+	c := (1 - math.Abs(2*l-1)) * s
+	return fromChroma(h, c, l-c/2)
+}
+
+// FromHSV converts hue in degrees and saturation and value in 0-1. HomeKit
+// reports color as hue and saturation, with brightness as the value.
+func FromHSV(h, s, v float64) RGB {
+	c := v * s
+	return fromChroma(h, c, v-c)
+}
+
+func fromChroma(h, c, m float64) RGB {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	to8 := func(v float64) uint8 { return uint8(math.Round((v + m) * 255)) }
+	return RGB{to8(r), to8(g), to8(b)}
+}
@@ -0,0 +1,83 @@
+package color
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"orange", "FFA500"},
+		{"RebeccaPurple", "663399"},
+		{" red ", "FF0000"},
+		{"#f60", "FF6600"},
+		{"#FF6600", "FF6600"},
+		{"ff6600", "FF6600"},
+		{"rgb(255,100,0)", "FF6400"},
+		{"rgb(255 100 0)", "FF6400"},
+		{"rgb(100%, 50%, 0%)", "FF8000"},
+		{"hsl(30,100%,50%)", "FF8000"},
+		{"hsl(210deg 50% 40%)", "336699"},
+		{"hsl(-120, 100%, 50%)", "0000FF"},
+		{"hsl(0, 0%, 100%)", "FFFFFF"},
+	}
+	for _, tt := range tests {
+		c, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", tt.in, err)
+			continue
+		}
+		if got := c.Hex(); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		in     string
+		errMsg string
+	}{
+		{"notacolor", "expected a CSS color name"},
+		{"f60", "expected a CSS color name"},
+		{"#GGGGGG", "is not a hex value"},
+		{"rgb(1,2)", "expected 3 values, got 2"},
+		{"rgb(1,2,x)", `"x" is not a number`},
+		{"rgb(1,2,300)", "300 is out of range 0-255"},
+		{"hsl(1,2%)", "expected 3 values, got 2"},
+		{"hsl(x,50%,50%)", `"x" is not a number`},
+		{"hsl(10,50%,y)", `"y" is not a number`},
+		{"hsl(10,150%,50%)", "150% is out of range 0-100%"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.in)
+		if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+			t.Errorf("Parse(%q): expected error containing %q, got %v", tt.in, tt.errMsg, err)
+		}
+	}
+}
+
+func TestFromHSV(t *testing.T) {
+	tests := []struct {
+		h, s, v float64
+		want    string
+	}{
+		{0, 1, 1, "FF0000"},
+		{60, 1, 1, "FFFF00"},
+		{120, 1, 1, "00FF00"},
+		{180, 1, 1, "00FFFF"},
+		{240, 1, 1, "0000FF"},
+		{300, 1, 1, "FF00FF"},
+		{30, 1, 1, "FF8000"},
+		{0, 0, 1, "FFFFFF"},
+		{360, 1, 0.5, "800000"},
+	}
+	for _, tt := range tests {
+		if got := FromHSV(tt.h, tt.s, tt.v).Hex(); got != tt.want {
+			t.Errorf("FromHSV(%v, %v, %v) = %s, want %s", tt.h, tt.s, tt.v, got, tt.want)
+		}
+	}
+}
@@ -5,16 +5,83 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 )
 
-var userHomeDir = os.UserHomeDir
+var (
+	userHomeDir = os.UserHomeDir
+
+	// systemConfigPath holds machine-wide defaults that the user's file
+	// only needs to override where it differs.
+	systemConfigPath = "/etc/itsyhome/config.json"
+)
 
 type Config struct {
-	Host string `json:"host"`
-	Port int    `json:"port"`
+	Host      string `json:"host,omitempty"`
+	Port      int    `json:"port,omitempty"`
+	Scheme    string `json:"scheme,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Token     string `json:"token,omitempty"`
+	TokenFile string `json:"token_file,omitempty"`
+
+	// TLS settings, used when the server is reached over https.
+	CACert     string `json:"ca_cert,omitempty"`
+	ClientCert string `json:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty"`
+	// InsecureSkipVerify is a pointer so that an explicit false in a higher
+	// layer can turn verification back on.
+	InsecureSkipVerify *bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// SkipVerify reports whether certificate verification is disabled.
+func (c Config) SkipVerify() bool {
+	return c.InsecureSkipVerify != nil && *c.InsecureSkipVerify
+}
+
+// File is the on-disk layout of config.json. The top-level host and port
+// form the "default" profile; named profiles live under "profiles".
+type File struct {
+	Config
+	ActiveProfile string            `json:"active_profile,omitempty"`
+	Profiles      map[string]Config `json:"profiles,omitempty"`
 }
 
-const defaultPort = 8423
+const (
+	defaultPort = 8423
+
+	// DefaultProfile names the top-level host and port in config.json.
+	DefaultProfile = "default"
+
+	// ProfileEnv selects the active profile, overriding active_profile.
+	ProfileEnv = "ITSYHOME_PROFILE"
+	// SystemConfigEnv replaces the path of the machine-wide config file.
+	SystemConfigEnv = "ITSYHOME_SYSTEM_CONFIG"
+
+	HostEnv      = "ITSYHOME_HOST"
+	PortEnv      = "ITSYHOME_PORT"
+	URLEnv       = "ITSYHOME_URL"
+	TokenEnv     = "ITSYHOME_TOKEN"
+	TokenFileEnv = "ITSYHOME_TOKEN_FILE"
+
+	SchemeEnv             = "ITSYHOME_SCHEME"
+	CACertEnv             = "ITSYHOME_CA_CERT"
+	ClientCertEnv         = "ITSYHOME_CLIENT_CERT"
+	ClientKeyEnv          = "ITSYHOME_CLIENT_KEY"
+	InsecureSkipVerifyEnv = "ITSYHOME_INSECURE_SKIP_VERIFY"
+)
+
+// Source records which layer an effective setting came from.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceSystem  Source = "system"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
 
 func DefaultConfig() Config {
 	return Config{
@@ -23,11 +90,35 @@ func DefaultConfig() Config {
 	}
 }
 
+// BaseURL returns URL when set, otherwise scheme://host:port with the
+// scheme defaulting to http.
 func (c Config) BaseURL() string {
-	return fmt.Sprintf("http://%s:%d", c.Host, c.Port)
+	if c.URL != "" {
+		return strings.TrimRight(c.URL, "/")
+	}
+	scheme := c.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, c.Host, c.Port)
+}
+
+func (c Config) withDefaults() Config {
+	if c.Port == 0 {
+		c.Port = defaultPort
+	}
+	if c.Host == "" {
+		c.Host = "localhost"
+	}
+	return c
 }
 
+// configPath returns $XDG_CONFIG_HOME/itsyhome/config.json, falling back to
+// ~/.config when XDG_CONFIG_HOME is unset or not absolute.
 func configPath() string {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, "itsyhome", "config.json")
+	}
 	home, err := userHomeDir()
 	if err != nil {
 		return ""
@@ -35,33 +126,40 @@ func configPath() string {
 	return filepath.Join(home, ".config", "itsyhome", "config.json")
 }
 
-func Load() Config {
-	path := configPath()
+// LoadFile reads the user's config.json as-is. A missing or unreadable file
+// yields an empty File.
+func LoadFile() File {
+	return readFile(configPath())
+}
+
+// LoadSystemFile reads the machine-wide config file as-is.
+func LoadSystemFile() File {
+	return readFile(SystemPath())
+}
+
+// LoadMerged returns the system file with the user's file layered on top.
+func LoadMerged() File {
+	return LoadSystemFile().Merge(LoadFile())
+}
+
+func readFile(path string) File {
 	if path == "" {
-		return DefaultConfig()
+		return File{}
 	}
 
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return DefaultConfig()
+		return File{}
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return DefaultConfig()
-	}
-
-	if cfg.Port == 0 {
-		cfg.Port = defaultPort
-	}
-	if cfg.Host == "" {
-		cfg.Host = "localhost"
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return File{}
 	}
-
-	return cfg
+	return f
 }
 
-func Save(cfg Config) error {
+func SaveFile(f File) error {
 	path := configPath()
 	if path == "" {
 		return fmt.Errorf("cannot determine config path")
@@ -72,10 +170,300 @@ func Save(cfg Config) error {
 		return fmt.Errorf("create config dir: %w", err)
 	}
 
-	data, _ := json.MarshalIndent(cfg, "", "  ")
+	data, _ := json.MarshalIndent(f, "", "  ")
 	return os.WriteFile(path, data, 0644)
 }
 
+// ResolveProfile returns the profile to use: name if non-empty, then
+// $ITSYHOME_PROFILE, then active_profile from the file, then "default".
+func (f File) ResolveProfile(name string) string {
+	if name != "" {
+		return name
+	}
+	if env := os.Getenv(ProfileEnv); env != "" {
+		return env
+	}
+	if f.ActiveProfile != "" {
+		return f.ActiveProfile
+	}
+	return DefaultProfile
+}
+
+// Merge returns f with every non-zero setting from over layered on top.
+// Profiles present in either file are kept.
+func (f File) Merge(over File) File {
+	out := File{
+		Config:        f.Config.Merge(over.Config),
+		ActiveProfile: f.ActiveProfile,
+	}
+	if over.ActiveProfile != "" {
+		out.ActiveProfile = over.ActiveProfile
+	}
+	if len(f.Profiles)+len(over.Profiles) > 0 {
+		out.Profiles = map[string]Config{}
+		for name, cfg := range f.Profiles {
+			out.Profiles[name] = cfg
+		}
+		for name, cfg := range over.Profiles {
+			out.Profiles[name] = out.Profiles[name].Merge(cfg)
+		}
+	}
+	return out
+}
+
+// setsAddress reports whether c gives host, port or scheme without a URL.
+// Such a layer replaces a URL from the layers below it, since the URL would
+// otherwise take precedence over the more specific setting.
+func (c Config) setsAddress() bool {
+	return c.URL == "" && (c.Host != "" || c.Port != 0 || c.Scheme != "")
+}
+
+// Merge returns c with every non-zero setting from over applied.
+func (c Config) Merge(over Config) Config {
+	if over.setsAddress() {
+		c.URL = ""
+	}
+	if over.Host != "" {
+		c.Host = over.Host
+	}
+	if over.Port != 0 {
+		c.Port = over.Port
+	}
+	if over.URL != "" {
+		c.URL = over.URL
+	}
+	// token and token_file are one setting; a token wins within a layer
+	if over.TokenFile != "" {
+		c.Token, c.TokenFile = "", over.TokenFile
+	}
+	if over.Token != "" {
+		c.Token, c.TokenFile = over.Token, ""
+	}
+	if over.Scheme != "" {
+		c.Scheme = over.Scheme
+	}
+	if over.CACert != "" {
+		c.CACert = over.CACert
+	}
+	if over.ClientCert != "" {
+		c.ClientCert = over.ClientCert
+	}
+	if over.ClientKey != "" {
+		c.ClientKey = over.ClientKey
+	}
+	if over.InsecureSkipVerify != nil {
+		c.InsecureSkipVerify = over.InsecureSkipVerify
+	}
+	return c
+}
+
+// Profile returns the named profile with defaults applied.
+func (f File) Profile(name string) (Config, error) {
+	cfg, ok := f.Lookup(name)
+	if !ok {
+		return Config{}, fmt.Errorf("unknown profile %q", name)
+	}
+	return cfg.withDefaults(), nil
+}
+
+// Lookup returns the named profile exactly as stored, without defaults.
+func (f File) Lookup(name string) (Config, bool) {
+	if name == DefaultProfile {
+		return f.Config, true
+	}
+	cfg, ok := f.Profiles[name]
+	return cfg, ok
+}
+
+// ProfileNames returns "default" followed by the named profiles, sorted.
+func (f File) ProfileNames() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{DefaultProfile}, names...)
+}
+
+// CheckProfileName rejects names that cannot be used for a profile: empty
+// names and names with path separators or "..", which would let the
+// profile's token file escape the config directory.
+func CheckProfileName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	return nil
+}
+
+// Resolved is the effective configuration together with the source of
+// each setting, keyed by its JSON name.
+type Resolved struct {
+	Config
+	Profile string
+	Sources map[string]Source
+}
+
+// Resolve builds the effective configuration for the given profile.
+// Non-zero settings are layered with the precedence flags > environment
+// ($ITSYHOME_HOST, $ITSYHOME_PORT, $ITSYHOME_URL) > user file > system
+// file > defaults. A host, port or scheme drops a URL from a lower layer,
+// and token and token_file replace each other. The profile's secret file
+// counts as part of the user file.
+func Resolve(profile string, flags Config) (Resolved, error) {
+	system, user := LoadSystemFile(), LoadFile()
+	name := system.Merge(user).ResolveProfile(profile)
+	fromSystem, inSystem := system.Lookup(name)
+	fromFile, inFile := user.Lookup(name)
+	if !inSystem && !inFile {
+		return Resolved{}, fmt.Errorf("unknown profile %q", name)
+	}
+	fromEnv, err := envConfig()
+	if err != nil {
+		return Resolved{}, err
+	}
+
+	r := Resolved{
+		Config:  DefaultConfig(),
+		Profile: name,
+		Sources: map[string]Source{"host": SourceDefault, "port": SourceDefault, "url": SourceDefault},
+	}
+	r.apply(fromSystem, SourceSystem)
+	r.apply(secretLayer(name), SourceFile)
+	r.apply(fromFile, SourceFile)
+	r.apply(fromEnv, SourceEnv)
+	r.apply(flags, SourceFlag)
+	if err := r.loadToken(); err != nil {
+		return Resolved{}, err
+	}
+	return r, nil
+}
+
+func (r *Resolved) apply(layer Config, src Source) {
+	if layer.setsAddress() && r.URL != "" {
+		r.URL = ""
+		r.Sources["url"] = SourceDefault
+	}
+	if layer.Host != "" {
+		r.Host = layer.Host
+		r.Sources["host"] = src
+	}
+	if layer.Port != 0 {
+		r.Port = layer.Port
+		r.Sources["port"] = src
+	}
+	if layer.URL != "" {
+		r.URL = layer.URL
+		r.Sources["url"] = src
+	}
+	if layer.TokenFile != "" {
+		r.Token, r.TokenFile = "", layer.TokenFile
+		delete(r.Sources, "token")
+		r.Sources["token_file"] = src
+	}
+	if layer.Token != "" {
+		r.Token, r.TokenFile = layer.Token, ""
+		delete(r.Sources, "token_file")
+		r.Sources["token"] = src
+	}
+	if layer.Scheme != "" {
+		r.Scheme = layer.Scheme
+		r.Sources["scheme"] = src
+	}
+	if layer.CACert != "" {
+		r.CACert = layer.CACert
+		r.Sources["ca_cert"] = src
+	}
+	if layer.ClientCert != "" {
+		r.ClientCert = layer.ClientCert
+		r.Sources["client_cert"] = src
+	}
+	if layer.ClientKey != "" {
+		r.ClientKey = layer.ClientKey
+		r.Sources["client_key"] = src
+	}
+	if layer.InsecureSkipVerify != nil {
+		r.InsecureSkipVerify = layer.InsecureSkipVerify
+		r.Sources["insecure_skip_verify"] = src
+	}
+}
+
+func envConfig() (Config, error) {
+	cfg := Config{
+		Host:      os.Getenv(HostEnv),
+		URL:       os.Getenv(URLEnv),
+		Token:     os.Getenv(TokenEnv),
+		TokenFile: os.Getenv(TokenFileEnv),
+
+		Scheme:     os.Getenv(SchemeEnv),
+		CACert:     os.Getenv(CACertEnv),
+		ClientCert: os.Getenv(ClientCertEnv),
+		ClientKey:  os.Getenv(ClientKeyEnv),
+	}
+	if v := os.Getenv(InsecureSkipVerifyEnv); v != "" {
+		insecure, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s %q", InsecureSkipVerifyEnv, v)
+		}
+		cfg.InsecureSkipVerify = &insecure
+	}
+	if v := os.Getenv(PortEnv); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil || port <= 0 {
+			return Config{}, fmt.Errorf("invalid %s %q", PortEnv, v)
+		}
+		cfg.Port = port
+	}
+	return cfg, nil
+}
+
+// LoadProfile resolves the profile to use (see File.ResolveProfile) and
+// returns its settings with environment overrides applied.
+func LoadProfile(name string) (Config, error) {
+	r, err := Resolve(name, Config{})
+	if err != nil {
+		return Config{}, err
+	}
+	return r.Config, nil
+}
+
+// Load returns the active profile, falling back to defaults if it does not
+// exist.
+func Load() Config {
+	cfg, err := LoadProfile("")
+	if err != nil {
+		return DefaultConfig()
+	}
+	return cfg
+}
+
+// SaveProfile stores cfg under the named profile, leaving other profiles
+// untouched.
+func SaveProfile(name string, cfg Config) error {
+	f := LoadFile()
+	if name == DefaultProfile || name == "" {
+		f.Config = cfg
+	} else {
+		if f.Profiles == nil {
+			f.Profiles = map[string]Config{}
+		}
+		f.Profiles[name] = cfg
+	}
+	return SaveFile(f)
+}
+
+func Save(cfg Config) error {
+	return SaveProfile(DefaultProfile, cfg)
+}
+
 func Path() string {
 	return configPath()
 }
+
+// SystemPath returns $ITSYHOME_SYSTEM_CONFIG, or /etc/itsyhome/config.json
+// when it is unset.
+func SystemPath() string {
+	if path := os.Getenv(SystemConfigEnv); path != "" {
+		return path
+	}
+	return systemConfigPath
+}
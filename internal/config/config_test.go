@@ -7,6 +7,19 @@ import (
 	"testing"
 )
 
+func TestMain(m *testing.M) {
+	// Tests point HOME at a temp dir; a developer's XDG_CONFIG_HOME would
+	// otherwise take precedence.
+	os.Unsetenv("XDG_CONFIG_HOME")
+	for _, env := range []string{
+		SystemConfigEnv, ProfileEnv, HostEnv, PortEnv, URLEnv, TokenEnv, TokenFileEnv,
+		SchemeEnv, CACertEnv, ClientCertEnv, ClientKeyEnv, InsecureSkipVerifyEnv,
+	} {
+		os.Unsetenv(env)
+	}
+	os.Exit(m.Run())
+}
+
 func TestDefaultConfig(t *testing.T) {
 	cfg := DefaultConfig()
 	if cfg.Host != "localhost" {
@@ -152,3 +165,389 @@ func TestSaveMkdirError(t *testing.T) {
 		t.Fatal("expected error from MkdirAll, got nil")
 	}
 }
+
+func TestLoadFileNoHome(t *testing.T) {
+	original := userHomeDir
+	userHomeDir = func() (string, error) { return "", fmt.Errorf("no home") }
+	defer func() { userHomeDir = original }()
+
+	f := LoadFile()
+	if f.Host != "" || len(f.Profiles) != 0 {
+		t.Errorf("expected empty file, got %+v", f)
+	}
+}
+
+func TestSaveProfileAndLoad(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	t.Setenv(ProfileEnv, "")
+
+	if err := Save(Config{Host: "home.local", Port: 8423}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := SaveProfile("office", Config{Host: "10.0.0.2"}); err != nil {
+		t.Fatalf("SaveProfile failed: %v", err)
+	}
+
+	cfg, err := LoadProfile("office")
+	if err != nil {
+		t.Fatalf("LoadProfile failed: %v", err)
+	}
+	if cfg.Host != "10.0.0.2" || cfg.Port != 8423 {
+		t.Errorf("expected office profile with default port, got %+v", cfg)
+	}
+
+	// The default profile must survive saving a named one
+	if got := Load(); got.Host != "home.local" {
+		t.Errorf("expected default profile host, got %s", got.Host)
+	}
+}
+
+func TestResolveProfile(t *testing.T) {
+	f := File{ActiveProfile: "cabin"}
+
+	t.Setenv(ProfileEnv, "")
+	if got := f.ResolveProfile("office"); got != "office" {
+		t.Errorf("explicit name: expected office, got %s", got)
+	}
+	if got := f.ResolveProfile(""); got != "cabin" {
+		t.Errorf("active_profile: expected cabin, got %s", got)
+	}
+	if got := (File{}).ResolveProfile(""); got != DefaultProfile {
+		t.Errorf("fallback: expected default, got %s", got)
+	}
+
+	t.Setenv(ProfileEnv, "home")
+	if got := f.ResolveProfile(""); got != "home" {
+		t.Errorf("env: expected home, got %s", got)
+	}
+}
+
+func TestProfileUnknown(t *testing.T) {
+	if _, err := (File{}).Profile("nope"); err == nil {
+		t.Fatal("expected error for unknown profile")
+	}
+}
+
+func TestLoadUnknownActiveProfile(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	t.Setenv(ProfileEnv, "missing")
+
+	cfg := Load()
+	if cfg != DefaultConfig() {
+		t.Errorf("expected defaults for unknown profile, got %+v", cfg)
+	}
+}
+
+func TestProfileNames(t *testing.T) {
+	f := File{Profiles: map[string]Config{"office": {}, "cabin": {}}}
+	names := f.ProfileNames()
+	want := []string{"default", "cabin", "office"}
+	if len(names) != len(want) {
+		t.Fatalf("expected %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, names)
+		}
+	}
+}
+
+func TestBaseURLOverride(t *testing.T) {
+	cfg := Config{Host: "ignored", Port: 1, URL: "http://mac.local:9000/"}
+	if cfg.BaseURL() != "http://mac.local:9000" {
+		t.Errorf("expected URL without trailing slash, got %s", cfg.BaseURL())
+	}
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	t.Setenv(ProfileEnv, "")
+	t.Setenv(HostEnv, "")
+	t.Setenv(PortEnv, "")
+	t.Setenv(URLEnv, "")
+
+	r, err := Resolve("", Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Config != DefaultConfig() || r.Sources["host"] != SourceDefault || r.Sources["port"] != SourceDefault {
+		t.Errorf("expected defaults, got %+v", r)
+	}
+
+	Save(Config{Host: "file.local", Port: 1000})
+	t.Setenv(PortEnv, "2000")
+	t.Setenv(URLEnv, "http://env.local:3000")
+
+	r, err = Resolve("", Config{URL: "http://flag.local:4000"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Host != "file.local" || r.Sources["host"] != SourceFile {
+		t.Errorf("host: expected file.local from file, got %s from %s", r.Host, r.Sources["host"])
+	}
+	if r.Port != 2000 || r.Sources["port"] != SourceEnv {
+		t.Errorf("port: expected 2000 from env, got %d from %s", r.Port, r.Sources["port"])
+	}
+	if r.URL != "http://flag.local:4000" || r.Sources["url"] != SourceFlag {
+		t.Errorf("url: expected flag value, got %s from %s", r.URL, r.Sources["url"])
+	}
+	if r.Profile != DefaultProfile {
+		t.Errorf("expected default profile, got %s", r.Profile)
+	}
+}
+
+func TestResolveAddressOverridesLowerURL(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	t.Setenv(ProfileEnv, "")
+	t.Setenv(HostEnv, "")
+	t.Setenv(PortEnv, "")
+	t.Setenv(URLEnv, "")
+
+	Save(Config{URL: "http://127.0.0.1:1"})
+
+	r, err := Resolve("", Config{Host: "127.0.0.1", Port: 18423})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.URL != "" || r.BaseURL() != "http://127.0.0.1:18423" {
+		t.Errorf("flags: expected host and port to win, got URL %q, base %s", r.URL, r.BaseURL())
+	}
+
+	t.Setenv(PortEnv, "2000")
+	r, err = Resolve("", Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.BaseURL() != "http://localhost:2000" || r.Sources["url"] != SourceDefault {
+		t.Errorf("env: expected port to win, got %s from %s", r.BaseURL(), r.Sources["url"])
+	}
+
+	// A URL in the same layer still takes precedence over host and port
+	t.Setenv(URLEnv, "http://env.local:3000")
+	r, err = Resolve("", Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.BaseURL() != "http://env.local:3000" {
+		t.Errorf("expected the env URL, got %s", r.BaseURL())
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(ProfileEnv, "")
+
+	if _, err := Resolve("missing", Config{}); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+
+	t.Setenv(PortEnv, "abc")
+	if _, err := Resolve("", Config{}); err == nil {
+		t.Error("expected error for invalid port")
+	}
+	if _, err := LoadProfile(""); err == nil {
+		t.Error("expected LoadProfile to surface invalid port")
+	}
+}
+
+func TestProfileAppliesDefaults(t *testing.T) {
+	f := File{Profiles: map[string]Config{"office": {Host: "10.0.0.2"}}}
+	cfg, err := f.Profile("office")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Host != "10.0.0.2" || cfg.Port != 8423 {
+		t.Errorf("expected host with default port, got %+v", cfg)
+	}
+	if cfg, _ := f.Profile(DefaultProfile); cfg != DefaultConfig() {
+		t.Errorf("expected empty default profile to fall back to defaults, got %+v", cfg)
+	}
+}
+
+func TestConfigPathXDG(t *testing.T) {
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+
+	want := filepath.Join(xdg, "itsyhome", "config.json")
+	if p := configPath(); p != want {
+		t.Errorf("expected %s, got %s", want, p)
+	}
+
+	// Relative values are ignored per the XDG spec
+	t.Setenv("XDG_CONFIG_HOME", "relative/dir")
+	if p := configPath(); p == filepath.Join("relative/dir", "itsyhome", "config.json") {
+		t.Errorf("expected relative XDG_CONFIG_HOME to be ignored, got %s", p)
+	}
+}
+
+func useSystemFile(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "system.json")
+	os.WriteFile(path, []byte(content), 0644)
+	original := systemConfigPath
+	systemConfigPath = path
+	t.Cleanup(func() { systemConfigPath = original })
+}
+
+func TestResolveSystemLayer(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv(ProfileEnv, "")
+	useSystemFile(t, `{"host":"jump.internal","port":9000,"active_profile":"lab","profiles":{"lab":{"host":"lab.internal","port":7000}}}`)
+
+	// The user file only overrides the lab port
+	SaveProfile("lab", Config{Port: 7100})
+
+	r, err := Resolve("", Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Profile != "lab" {
+		t.Errorf("expected active profile from system file, got %s", r.Profile)
+	}
+	if r.Host != "lab.internal" || r.Sources["host"] != SourceSystem {
+		t.Errorf("host: expected lab.internal from system, got %s from %s", r.Host, r.Sources["host"])
+	}
+	if r.Port != 7100 || r.Sources["port"] != SourceFile {
+		t.Errorf("port: expected 7100 from file, got %d from %s", r.Port, r.Sources["port"])
+	}
+
+	if m := LoadMerged(); m.Profiles["lab"] != (Config{Host: "lab.internal", Port: 7100}) {
+		t.Errorf("unexpected merged lab profile: %+v", m.Profiles["lab"])
+	}
+
+	r, _ = Resolve(DefaultProfile, Config{})
+	if r.Host != "jump.internal" || r.Port != 9000 {
+		t.Errorf("expected system default profile, got %+v", r.Config)
+	}
+
+	if SystemPath() != systemConfigPath {
+		t.Errorf("unexpected system path %s", SystemPath())
+	}
+
+	t.Setenv(SystemConfigEnv, filepath.Join(t.TempDir(), "missing.json"))
+	if r, _ := Resolve("", Config{}); r.Profile != DefaultProfile || r.Host != "localhost" {
+		t.Errorf("expected $%s to replace the system file, got %+v", SystemConfigEnv, r)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := File{
+		Config:        Config{Host: "a", Port: 1},
+		ActiveProfile: "x",
+		Profiles:      map[string]Config{"x": {Host: "xa", Port: 10}},
+	}
+	over := File{
+		Config:   Config{URL: "http://b", Token: "t", TokenFile: "/tf"},
+		Profiles: map[string]Config{"x": {Port: 11}, "y": {Host: "ya"}},
+	}
+
+	m := base.Merge(over)
+	if m.Host != "a" || m.Port != 1 || m.URL != "http://b" || m.Token != "t" || m.TokenFile != "" {
+		t.Errorf("unexpected top-level merge: %+v", m.Config)
+	}
+	if m.ActiveProfile != "x" {
+		t.Errorf("expected active profile kept, got %s", m.ActiveProfile)
+	}
+	if m.Profiles["x"] != (Config{Host: "xa", Port: 11}) || m.Profiles["y"].Host != "ya" {
+		t.Errorf("unexpected profile merge: %+v", m.Profiles)
+	}
+
+	if m := base.Merge(File{ActiveProfile: "y"}); m.ActiveProfile != "y" {
+		t.Errorf("expected override active profile, got %s", m.ActiveProfile)
+	}
+	if m := (File{}).Merge(File{}); m.Profiles != nil {
+		t.Errorf("expected nil profiles, got %+v", m.Profiles)
+	}
+}
+
+func TestMergeAddressDropsURL(t *testing.T) {
+	base := Config{URL: "http://mac.local:8423", Host: "old"}
+	if got := base.Merge(Config{Port: 9000}); got.URL != "" || got.Port != 9000 {
+		t.Errorf("port: expected URL dropped, got %+v", got)
+	}
+	if got := base.Merge(Config{Scheme: "https"}); got.URL != "" {
+		t.Errorf("scheme: expected URL dropped, got %+v", got)
+	}
+	if got := base.Merge(Config{Host: "new", URL: "http://new:1"}); got.URL != "http://new:1" {
+		t.Errorf("expected URL from the same layer, got %+v", got)
+	}
+	if got := base.Merge(Config{Token: "t"}); got.URL != base.URL {
+		t.Errorf("expected URL kept, got %+v", got)
+	}
+}
+
+func TestBaseURLScheme(t *testing.T) {
+	cfg := Config{Scheme: "https", Host: "mac.local", Port: 443}
+	if cfg.BaseURL() != "https://mac.local:443" {
+		t.Errorf("unexpected URL %s", cfg.BaseURL())
+	}
+}
+
+func TestResolveTLSSettings(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(ProfileEnv, "")
+	Save(Config{Scheme: "https", CACert: "/etc/ssl/lab.pem"})
+	t.Setenv(ClientCertEnv, "/certs/cli.pem")
+	t.Setenv(ClientKeyEnv, "/certs/cli-key.pem")
+	t.Setenv(InsecureSkipVerifyEnv, "true")
+
+	r, err := Resolve("", Config{Scheme: "http"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Scheme != "http" || r.Sources["scheme"] != SourceFlag {
+		t.Errorf("scheme: expected http from flag, got %s from %s", r.Scheme, r.Sources["scheme"])
+	}
+	if r.CACert != "/etc/ssl/lab.pem" || r.Sources["ca_cert"] != SourceFile {
+		t.Errorf("ca_cert: got %s from %s", r.CACert, r.Sources["ca_cert"])
+	}
+	if r.ClientCert != "/certs/cli.pem" || r.ClientKey != "/certs/cli-key.pem" || r.Sources["client_key"] != SourceEnv {
+		t.Errorf("client cert: got %s/%s from %s", r.ClientCert, r.ClientKey, r.Sources["client_key"])
+	}
+	if !r.SkipVerify() || r.Sources["insecure_skip_verify"] != SourceEnv {
+		t.Errorf("insecure: got %v from %s", r.SkipVerify(), r.Sources["insecure_skip_verify"])
+	}
+
+	off := false
+	r, err = Resolve("", Config{InsecureSkipVerify: &off})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.SkipVerify() || r.Sources["insecure_skip_verify"] != SourceFlag {
+		t.Errorf("insecure: expected flag to turn verification back on, got %v from %s", r.SkipVerify(), r.Sources["insecure_skip_verify"])
+	}
+
+	on := true
+	Save(Config{InsecureSkipVerify: &on})
+	t.Setenv(InsecureSkipVerifyEnv, "false")
+	r, err = Resolve("", Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.SkipVerify() || r.Sources["insecure_skip_verify"] != SourceEnv {
+		t.Errorf("insecure: expected env to turn verification back on, got %v from %s", r.SkipVerify(), r.Sources["insecure_skip_verify"])
+	}
+
+	t.Setenv(InsecureSkipVerifyEnv, "maybe")
+	if _, err := Resolve("", Config{}); err == nil {
+		t.Error("expected error for invalid boolean")
+	}
+}
+
+func TestConfigMergeTLS(t *testing.T) {
+	on, off := true, false
+	base := Config{Scheme: "http", CACert: "a", InsecureSkipVerify: &on}
+	got := base.Merge(Config{Scheme: "https", ClientCert: "c", ClientKey: "k", InsecureSkipVerify: &off})
+	want := Config{Scheme: "https", CACert: "a", ClientCert: "c", ClientKey: "k", InsecureSkipVerify: &off}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if got := base.Merge(Config{}); !got.SkipVerify() {
+		t.Error("expected insecure_skip_verify kept when not set")
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TokenPath returns the secret file holding the API token for a profile.
+// It lives next to config.json, under tokens/ for named profiles, and must
+// not be readable by other users.
+func TokenPath(profile string) string {
+	path := configPath()
+	if path == "" {
+		return ""
+	}
+	if profile == "" || profile == DefaultProfile {
+		return filepath.Join(filepath.Dir(path), "token")
+	}
+	// The name becomes a file name, so it must not lead out of tokens/
+	if CheckProfileName(profile) != nil {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(path), "tokens", profile)
+}
+
+// SaveToken writes the profile's API token to TokenPath with 0600
+// permissions.
+func SaveToken(profile, token string) error {
+	if profile != "" {
+		if err := CheckProfileName(profile); err != nil {
+			return err
+		}
+	}
+	path := TokenPath(profile)
+	if path == "" {
+		return fmt.Errorf("cannot determine config path")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create config dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file, so tighten it explicitly
+	return os.Chmod(path, 0600)
+}
+
+// secretLayer returns the profile's secret file as a token_file setting if
+// the file exists, so it takes part in the user file layer.
+func secretLayer(profile string) Config {
+	path := TokenPath(profile)
+	if path == "" {
+		return Config{}
+	}
+	if _, err := os.Stat(path); err != nil {
+		return Config{}
+	}
+	return Config{TokenFile: path}
+}
+
+// loadToken fills in Token from token_file when that is what the highest
+// layer gave.
+func (r *Resolved) loadToken() error {
+	if r.TokenFile == "" {
+		return nil
+	}
+	token, err := readSecret(r.TokenFile)
+	if err != nil {
+		return err
+	}
+	r.Token = token
+	r.Sources["token"] = r.Sources["token_file"]
+	return nil
+}
+
+func readSecret(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("read token file: %w", err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("token file %s is accessible by other users (mode %04o); run: chmod 600 %s",
+			path, info.Mode().Perm(), path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read token file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
+}
@@ -0,0 +1,252 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func clearTokenEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv(ProfileEnv, "")
+	t.Setenv(TokenEnv, "")
+	t.Setenv(TokenFileEnv, "")
+}
+
+func TestSaveTokenAndResolve(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	clearTokenEnv(t)
+
+	if err := SaveToken("", "s3cret"); err != nil {
+		t.Fatalf("SaveToken failed: %v", err)
+	}
+	info, err := os.Stat(TokenPath(""))
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %04o", info.Mode().Perm())
+	}
+
+	r, err := Resolve("", Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Token != "s3cret" || r.Sources["token"] != SourceFile {
+		t.Errorf("expected token from secret file, got %q from %s", r.Token, r.Sources["token"])
+	}
+
+	t.Setenv(TokenEnv, "from-env")
+	r, _ = Resolve("", Config{})
+	if r.Token != "from-env" || r.Sources["token"] != SourceEnv {
+		t.Errorf("expected token from env, got %q from %s", r.Token, r.Sources["token"])
+	}
+}
+
+func TestSaveTokenTightensMode(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+
+	path := TokenPath("")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, []byte("old"), 0644)
+
+	if err := SaveToken("", "new"); err != nil {
+		t.Fatalf("SaveToken failed: %v", err)
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %04o", info.Mode().Perm())
+	}
+}
+
+func TestSaveTokenErrors(t *testing.T) {
+	original := userHomeDir
+	userHomeDir = func() (string, error) { return "", fmt.Errorf("no home") }
+	if err := SaveToken("", "x"); err == nil {
+		t.Error("expected error without config path")
+	}
+	if TokenPath("") != "" {
+		t.Error("expected empty token path without home")
+	}
+	userHomeDir = original
+
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	configDir := filepath.Join(tmp, ".config", "itsyhome")
+	os.MkdirAll(filepath.Dir(configDir), 0755)
+	os.WriteFile(configDir, []byte("not a dir"), 0644)
+	if err := SaveToken("", "x"); err == nil {
+		t.Error("expected mkdir error")
+	}
+
+	os.Remove(configDir)
+	os.MkdirAll(filepath.Join(configDir, "token"), 0755)
+	if err := SaveToken("", "x"); err == nil {
+		t.Error("expected write error when token path is a directory")
+	}
+}
+
+func TestResolveTokenFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	clearTokenEnv(t)
+
+	path := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(path, []byte("  from-file\n"), 0600)
+
+	r, err := Resolve("", Config{TokenFile: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Token != "from-file" || r.Sources["token"] != SourceFlag {
+		t.Errorf("expected trimmed token from flag token file, got %q from %s", r.Token, r.Sources["token"])
+	}
+
+	t.Setenv(TokenFileEnv, filepath.Join(t.TempDir(), "missing"))
+	if _, err := Resolve("", Config{}); err == nil {
+		t.Error("expected error for missing token file")
+	}
+}
+
+func TestResolveNoHomeNoToken(t *testing.T) {
+	clearTokenEnv(t)
+	original := userHomeDir
+	userHomeDir = func() (string, error) { return "", fmt.Errorf("no home") }
+	defer func() { userHomeDir = original }()
+
+	r, err := Resolve("", Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Token != "" {
+		t.Errorf("expected no token, got %q", r.Token)
+	}
+}
+
+func TestResolveInsecureSecretFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	clearTokenEnv(t)
+
+	SaveToken("", "s3cret")
+	os.Chmod(TokenPath(""), 0644)
+
+	_, err := Resolve("", Config{})
+	if err == nil || !strings.Contains(err.Error(), "chmod 600") {
+		t.Errorf("expected permissions error, got %v", err)
+	}
+}
+
+func TestReadSecretErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	empty := filepath.Join(dir, "empty")
+	os.WriteFile(empty, []byte("\n"), 0600)
+	if _, err := readSecret(empty); err == nil {
+		t.Error("expected error for empty token file")
+	}
+
+	sub := filepath.Join(dir, "dir")
+	os.Mkdir(sub, 0700)
+	if _, err := readSecret(sub); err == nil {
+		t.Error("expected error reading a directory")
+	}
+}
+
+func TestTokenPerProfile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	clearTokenEnv(t)
+
+	SaveProfile("lab", Config{Host: "lab.local"})
+	SaveProfile("office", Config{Host: "office.local"})
+	if err := SaveToken("", "home-token"); err != nil {
+		t.Fatalf("SaveToken failed: %v", err)
+	}
+	if err := SaveToken("lab", "lab-token"); err != nil {
+		t.Fatalf("SaveToken failed: %v", err)
+	}
+	if TokenPath("lab") == TokenPath(DefaultProfile) {
+		t.Fatal("expected a separate secret file per profile")
+	}
+
+	for profile, want := range map[string]string{"": "home-token", "lab": "lab-token", "office": ""} {
+		r, err := Resolve(profile, Config{})
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", profile, err)
+		}
+		if r.Token != want {
+			t.Errorf("%q: expected token %q, got %q", profile, want, r.Token)
+		}
+	}
+}
+
+func TestResolveTokenAndTokenFileLayering(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	clearTokenEnv(t)
+
+	path := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(path, []byte("from-token-file"), 0600)
+	Save(Config{Token: "from-config"})
+
+	// A token_file from the environment beats a token from config.json
+	t.Setenv(TokenFileEnv, path)
+	r, err := Resolve("", Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Token != "from-token-file" || r.Sources["token"] != SourceEnv {
+		t.Errorf("expected token from env token file, got %q from %s", r.Token, r.Sources["token"])
+	}
+
+	// A token flag beats the token_file from the environment
+	r, err = Resolve("", Config{Token: "from-flag"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Token != "from-flag" || r.TokenFile != "" || r.Sources["token"] != SourceFlag {
+		t.Errorf("expected token from flag, got %q from %s", r.Token, r.Sources["token"])
+	}
+
+	// A token in config.json beats the profile's secret file
+	t.Setenv(TokenFileEnv, "")
+	SaveToken("", "from-secret")
+	r, _ = Resolve("", Config{})
+	if r.Token != "from-config" {
+		t.Errorf("expected token from config.json, got %q", r.Token)
+	}
+}
+
+func TestMergeTokenSetting(t *testing.T) {
+	base := Config{Token: "t", TokenFile: ""}
+	if got := base.Merge(Config{TokenFile: "/f"}); got.Token != "" || got.TokenFile != "/f" {
+		t.Errorf("expected token_file to replace token, got %+v", got)
+	}
+	if got := (Config{TokenFile: "/f"}).Merge(Config{Token: "t"}); got.Token != "t" || got.TokenFile != "" {
+		t.Errorf("expected token to replace token_file, got %+v", got)
+	}
+}
+
+func TestTokenPathRejectsUnsafeNames(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	for _, name := range []string{"../../evil", "a/b", `a\b`, ".."} {
+		if err := CheckProfileName(name); err == nil {
+			t.Errorf("%q: expected invalid profile name", name)
+		}
+		if path := TokenPath(name); path != "" {
+			t.Errorf("%q: expected no token path, got %s", name, path)
+		}
+		if err := SaveToken(name, "s3cret"); err == nil || !strings.Contains(err.Error(), "invalid profile name") {
+			t.Errorf("%q: expected invalid profile name error, got %v", name, err)
+		}
+	}
+	if err := CheckProfileName(""); err == nil {
+		t.Error("expected an empty name to be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config", "evil")); err == nil {
+		t.Error("a token must not be written outside the config directory")
+	}
+}
@@ -0,0 +1,378 @@
+package display
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A JSONPath template is literal text with {expressions} in it, as in
+// kubectl: {.name}, {.state.brightness}, {[0].name}, {[*].room},
+// {range [*]}{.name}{"\n"}{end}. Expressions use the JSON field names and
+// are evaluated against Data after a round trip through encoding/json.
+
+type stepKind int
+
+const (
+	stepField stepKind = iota
+	stepIndex
+	stepAll
+)
+
+type pathStep struct {
+	kind  stepKind
+	field string
+	index int
+}
+
+type jsonPath struct {
+	src   string
+	root  bool // starts with $, so it ignores the current {range} item
+	steps []pathStep
+}
+
+type pathNode struct {
+	text    string
+	path    *jsonPath
+	isRange bool
+	body    []pathNode
+}
+
+// parsePath parses one expression such as $.items[0].name or .state.on.
+func parsePath(src string) (*jsonPath, error) {
+	p := &jsonPath{src: src}
+	s := src
+	switch {
+	case strings.HasPrefix(s, "$"):
+		p.root, s = true, s[1:]
+	case strings.HasPrefix(s, "@"):
+		s = s[1:]
+	}
+	if s == "." {
+		return p, nil
+	}
+	for s != "" {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			name := s[:end]
+			s = s[end:]
+			switch name {
+			case "":
+				return nil, fmt.Errorf("invalid jsonpath %q: expected a field name after \".\"", src)
+			case "*":
+				p.steps = append(p.steps, pathStep{kind: stepAll})
+			default:
+				p.steps = append(p.steps, pathStep{kind: stepField, field: name})
+			}
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid jsonpath %q: missing ]", src)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			if inner == "*" {
+				p.steps = append(p.steps, pathStep{kind: stepAll})
+				continue
+			}
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				p.steps = append(p.steps, pathStep{kind: stepField, field: inner[1 : len(inner)-1]})
+				continue
+			}
+			i, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jsonpath %q: bad index [%s]", src, inner)
+			}
+			p.steps = append(p.steps, pathStep{kind: stepIndex, index: i})
+		default:
+			return nil, fmt.Errorf("invalid jsonpath %q: expected . or [ at %q", src, s)
+		}
+	}
+	return p, nil
+}
+
+// eval returns every value the path selects from v, in order.
+func (p *jsonPath) eval(v interface{}) []interface{} {
+	vals := []interface{}{v}
+	for _, st := range p.steps {
+		var next []interface{}
+		for _, v := range vals {
+			switch st.kind {
+			case stepField:
+				if m, ok := v.(map[string]interface{}); ok {
+					if x, ok := m[st.field]; ok {
+						next = append(next, x)
+					}
+				}
+			case stepIndex:
+				if list, ok := v.([]interface{}); ok {
+					i := st.index
+					if i < 0 {
+						i += len(list)
+					}
+					if i >= 0 && i < len(list) {
+						next = append(next, list[i])
+					}
+				}
+			case stepAll:
+				switch x := v.(type) {
+				case []interface{}:
+					next = append(next, x...)
+				case map[string]interface{}:
+					keys := make([]string, 0, len(x))
+					for k := range x {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, x[k])
+					}
+				}
+			}
+		}
+		vals = next
+	}
+	return vals
+}
+
+type templateParser struct {
+	src string
+	pos int
+}
+
+func parseJSONPathTemplate(src string) ([]pathNode, error) {
+	p := &templateParser{src: src}
+	nodes, end, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	if end {
+		return nil, fmt.Errorf("invalid jsonpath: {end} without {range}")
+	}
+	return nodes, nil
+}
+
+// parse reads nodes up to the end of the template or an {end}, which it
+// reports so {range} can tell its body was closed.
+func (p *templateParser) parse() ([]pathNode, bool, error) {
+	var nodes []pathNode
+	for p.pos < len(p.src) {
+		open := strings.IndexByte(p.src[p.pos:], '{')
+		if open < 0 {
+			nodes = append(nodes, pathNode{text: p.src[p.pos:]})
+			break
+		}
+		if open > 0 {
+			nodes = append(nodes, pathNode{text: p.src[p.pos : p.pos+open]})
+		}
+		p.pos += open
+		expr, err := p.action()
+		if err != nil {
+			return nil, false, err
+		}
+		switch {
+		case expr == "end":
+			return nodes, true, nil
+		case strings.HasPrefix(expr, "range "):
+			path, err := parsePath(strings.TrimSpace(expr[len("range "):]))
+			if err != nil {
+				return nil, false, err
+			}
+			body, end, err := p.parse()
+			if err != nil {
+				return nil, false, err
+			}
+			if !end {
+				return nil, false, fmt.Errorf("invalid jsonpath: {range %s} without {end}", path.src)
+			}
+			nodes = append(nodes, pathNode{path: path, isRange: true, body: body})
+		case strings.HasPrefix(expr, `"`):
+			text, err := strconv.Unquote(expr)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid jsonpath string %s", expr)
+			}
+			nodes = append(nodes, pathNode{text: text})
+		default:
+			path, err := parsePath(expr)
+			if err != nil {
+				return nil, false, err
+			}
+			nodes = append(nodes, pathNode{path: path})
+		}
+	}
+	return nodes, false, nil
+}
+
+// action returns the trimmed text between the { at p.pos and its closing },
+// skipping braces inside quoted strings.
+func (p *templateParser) action() (string, error) {
+	quoted, escaped := false, false
+	for i := p.pos + 1; i < len(p.src); i++ {
+		c := p.src[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == '}' && !quoted:
+			expr := strings.TrimSpace(p.src[p.pos+1 : i])
+			p.pos = i + 1
+			return expr, nil
+		}
+	}
+	return "", fmt.Errorf("invalid jsonpath: unclosed { in %q", p.src[p.pos:])
+}
+
+// toGeneric converts v to the maps, slices and json.Numbers encoding/json
+// produces, which is what paths are evaluated against.
+func toGeneric(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic interface{}
+	// data comes from json.Marshal, so it always decodes
+	dec.Decode(&generic)
+	return generic, nil
+}
+
+// formatValue prints strings and numbers as they are and anything else as
+// compact JSON.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func joinValues(vals []interface{}) string {
+	parts := make([]string, len(vals))
+	for i, v := range vals {
+		parts[i] = formatValue(v)
+	}
+	return strings.Join(parts, " ")
+}
+
+type jsonPathRenderer struct {
+	nodes []pathNode
+}
+
+func newJSONPathRenderer(src string) (Renderer, error) {
+	if src == "" {
+		return nil, fmt.Errorf("jsonpath needs an expression, e.g. jsonpath='{.name}'")
+	}
+	nodes, err := parseJSONPathTemplate(src)
+	if err != nil {
+		return nil, err
+	}
+	return jsonPathRenderer{nodes: nodes}, nil
+}
+
+func (r jsonPathRenderer) Render(w io.Writer, out Output) error {
+	root, err := toGeneric(out.Data)
+	if err != nil {
+		return err
+	}
+	var buf strings.Builder
+	if err := execNodes(&buf, r.nodes, root, root); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, buf.String())
+	return err
+}
+
+func execNodes(buf *strings.Builder, nodes []pathNode, root, cur interface{}) error {
+	for _, n := range nodes {
+		if n.path == nil {
+			buf.WriteString(n.text)
+			continue
+		}
+		base := cur
+		if n.path.root {
+			base = root
+		}
+		vals := n.path.eval(base)
+		if !n.isRange {
+			if len(vals) == 0 {
+				return fmt.Errorf("%s is not found", n.path.src)
+			}
+			buf.WriteString(joinValues(vals))
+			continue
+		}
+		// {range .items} walks the list itself, like {range .items[*]}
+		if len(vals) == 1 {
+			if list, ok := vals[0].([]interface{}); ok {
+				vals = list
+			}
+		}
+		for _, item := range vals {
+			if err := execNodes(buf, n.body, root, item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fieldRenderer prints one field per line: of Data itself, or of each
+// element when Data is a list. Elements without the field print an empty
+// line so lines still match up with the list.
+type fieldRenderer struct {
+	path *jsonPath
+}
+
+func newFieldRenderer(field string) (Renderer, error) {
+	if field == "" {
+		return nil, fmt.Errorf("--field needs a field name, e.g. --field name")
+	}
+	src := field
+	if !strings.HasPrefix(src, ".") && !strings.HasPrefix(src, "[") {
+		src = "." + src
+	}
+	path, err := parsePath(src)
+	if err != nil {
+		return nil, err
+	}
+	path.src = field
+	return fieldRenderer{path: path}, nil
+}
+
+func (r fieldRenderer) Render(w io.Writer, out Output) error {
+	data, err := toGeneric(out.Data)
+	if err != nil {
+		return err
+	}
+	items, ok := data.([]interface{})
+	if !ok {
+		items = []interface{}{data}
+	}
+	var buf strings.Builder
+	found := false
+	for _, item := range items {
+		vals := r.path.eval(item)
+		found = found || len(vals) > 0
+		buf.WriteString(joinValues(vals) + "\n")
+	}
+	if len(items) > 0 && !found {
+		return fmt.Errorf("field %q not found", r.path.src)
+	}
+	_, err = io.WriteString(w, buf.String())
+	return err
+}
@@ -0,0 +1,135 @@
+package display
+
+import (
+	"bytes"
+	"testing"
+)
+
+type pathDevice struct {
+	Name  string                 `json:"name"`
+	Room  string                 `json:"room,omitempty"`
+	State map[string]interface{} `json:"state,omitempty"`
+}
+
+var pathDevices = []pathDevice{
+	{Name: "Lamp", Room: "Office", State: map[string]interface{}{"on": true, "brightness": 80}},
+	{Name: "Fan", Room: "Office", State: map[string]interface{}{"on": false}},
+	{Name: "Porch Light"},
+}
+
+func TestJSONPathRender(t *testing.T) {
+	tests := []struct {
+		expr string
+		data interface{}
+		want string
+	}{
+		{"{.name}", pathDevices[0], "Lamp"},
+		{"{.name}!", pathDevices[0], "Lamp!"},
+		{"{.state.brightness}", pathDevices[0], "80"},
+		{"{.state}", pathDevices[0], `{"brightness":80,"on":true}`},
+		{"{.state.*}", pathDevices[0], "80 true"},
+		{"{[0].name}", pathDevices, "Lamp"},
+		{"{[-1].name}", pathDevices, "Porch Light"},
+		{"{[*].name}", pathDevices, "Lamp Fan Porch Light"},
+		{"{.*.name}", pathDevices, "Lamp Fan Porch Light"},
+		{"{[1]['name']}", pathDevices, "Fan"},
+		{`{range .}{.name}: {$[0].room}{"\n"}{end}`, pathDevices, "Lamp: Office\nFan: Office\nPorch Light: Office\n"},
+		{"{range [*]}[{@.name}]{end}", pathDevices[:2], "[Lamp][Fan]"},
+		{"{range [0].state}{.}{end}", pathDevices, `{"brightness":80,"on":true}`},
+		{"name={$.name} on={.state.on}", pathDevices[1], "name=Fan on=false"},
+		{"{.}", nil, "null"},
+		{`{"{}"}`, nil, "{}"},
+	}
+	for _, tt := range tests {
+		got := render(t, Format("jsonpath="+tt.expr), Output{Data: tt.data})
+		if got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.expr, tt.want, got)
+		}
+	}
+}
+
+func TestJSONPathNotFound(t *testing.T) {
+	for _, expr := range []string{"{.room}", "{[5].name}", "{.name.first}", "{range [*]}{.room}{end}"} {
+		r, err := NewRenderer(Format("jsonpath=" + expr))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", expr, err)
+		}
+		err = r.Render(&bytes.Buffer{}, Output{Data: pathDevices[2:]})
+		if err == nil {
+			t.Errorf("%s: expected not found error, got nil", expr)
+		}
+	}
+}
+
+func TestJSONPathParseErrors(t *testing.T) {
+	tests := []struct {
+		expr   string
+		errMsg string
+	}{
+		{"", "jsonpath needs an expression, e.g. jsonpath='{.name}'"},
+		{"{.name", `invalid jsonpath: unclosed { in "{.name"`},
+		{"{..name}", `invalid jsonpath "..name": expected a field name after "."`},
+		{"{name}", `invalid jsonpath "name": expected . or [ at "name"`},
+		{"{[0}", `invalid jsonpath "[0": missing ]`},
+		{"{[x]}", `invalid jsonpath "[x]": bad index [x]`},
+		{`{"\q"}`, `invalid jsonpath string "\q"`},
+		{"{end}", "invalid jsonpath: {end} without {range}"},
+		{"{range .}{.name}", "invalid jsonpath: {range .} without {end}"},
+		{"{range x}{end}", `invalid jsonpath "x": expected . or [ at "x"`},
+		{"{range .}{x}{end}", `invalid jsonpath "x": expected . or [ at "x"`},
+	}
+	for _, tt := range tests {
+		if _, err := NewRenderer(Format("jsonpath=" + tt.expr)); err == nil || err.Error() != tt.errMsg {
+			t.Errorf("%q: expected %q, got %v", tt.expr, tt.errMsg, err)
+		}
+	}
+}
+
+func TestGoTemplateRender(t *testing.T) {
+	got := render(t, Format(`go-template={{range .}}{{.Name}}{{"\n"}}{{end}}`), Output{Data: pathDevices})
+	if got != "Lamp\nFan\nPorch Light\n" {
+		t.Errorf("unexpected output: %q", got)
+	}
+
+	for _, f := range []Format{"go-template=", "go-template={{.Name"} {
+		if _, err := NewRenderer(f); err == nil {
+			t.Errorf("%s: expected parse error, got nil", f)
+		}
+	}
+
+	r, _ := NewRenderer("go-template={{.Missing}}")
+	if err := r.Render(&bytes.Buffer{}, Output{Data: pathDevices[0]}); err == nil {
+		t.Error("expected execution error, got nil")
+	}
+}
+
+func TestFieldRender(t *testing.T) {
+	tests := []struct {
+		field string
+		data  interface{}
+		want  string
+	}{
+		{"state.brightness", pathDevices[:1], "80\n"},
+		{"name", pathDevices, "Lamp\nFan\nPorch Light\n"},
+		{"room", pathDevices, "Office\nOffice\n\n"},
+		{".name", pathDevices[1], "Fan\n"},
+		{"[0]", [][]string{{"a", "b"}}, "a\n"},
+		{"name", []pathDevice{}, ""},
+	}
+	for _, tt := range tests {
+		got := render(t, Format("field="+tt.field), Output{Data: tt.data})
+		if got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.field, tt.want, got)
+		}
+	}
+
+	r, _ := NewRenderer("field=state.hue")
+	if err := r.Render(&bytes.Buffer{}, Output{Data: pathDevices}); err == nil || err.Error() != `field "state.hue" not found` {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, f := range []Format{"field=", "field=state..on"} {
+		if _, err := NewRenderer(f); err == nil {
+			t.Errorf("%s: expected parse error, got nil", f)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package display
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Format is an output format selected with --output.
+type Format string
+
+const (
+	FormatTable    Format = "table"
+	FormatJSON     Format = "json"
+	FormatNDJSON   Format = "ndjson"
+	FormatYAML     Format = "yaml"
+	FormatCSV      Format = "csv"
+	FormatTSV      Format = "tsv"
+	FormatMarkdown Format = "markdown"
+
+	// These take an argument after "=", e.g. jsonpath={.name}. FormatField
+	// is what --field selects.
+	FormatGoTemplate Format = "go-template"
+	FormatJSONPath   Format = "jsonpath"
+	FormatField      Format = "field"
+)
+
+// Formats lists the formats without an argument in the order shown in help
+// text.
+var Formats = []Format{FormatTable, FormatJSON, FormatNDJSON, FormatYAML, FormatCSV, FormatTSV, FormatMarkdown}
+
+func (f *Format) String() string { return string(*f) }
+
+func (f *Format) Type() string { return "format" }
+
+// Set implements pflag.Value so a Format can be bound to a flag directly.
+// Templates and paths are parsed here so mistakes are reported up front.
+func (f *Format) Set(s string) error {
+	if _, err := NewRenderer(Format(s)); err != nil {
+		return err
+	}
+	*f = Format(s)
+	return nil
+}
+
+// Kind returns the format without its argument, e.g. FormatJSONPath for
+// "jsonpath={.name}".
+func (f Format) Kind() Format {
+	kind, _, _ := strings.Cut(string(f), "=")
+	return Format(kind)
+}
+
+// Output is the result of a command. Data is what json, ndjson and yaml
+// serialise; Table holds the same result for table, csv, tsv and markdown.
+// Text, when set, is printed instead of Table in table format for commands
+// whose human-readable layout is not a single table.
+type Output struct {
+	Data  interface{}
+	Table *Table
+	Text  string
+}
+
+type Renderer interface {
+	Render(w io.Writer, out Output) error
+}
+
+// NewRenderer returns the Renderer for f, or an error if f is unknown or
+// its template or path does not parse.
+func NewRenderer(f Format) (Renderer, error) {
+	_, arg, _ := strings.Cut(string(f), "=")
+	switch f.Kind() {
+	case FormatGoTemplate:
+		return newTemplateRenderer(arg)
+	case FormatJSONPath:
+		return newJSONPathRenderer(arg)
+	case FormatField:
+		return newFieldRenderer(arg)
+	}
+	switch f {
+	case FormatTable:
+		return tableRenderer{}, nil
+	case FormatJSON:
+		return jsonRenderer{}, nil
+	case FormatNDJSON:
+		return ndjsonRenderer{}, nil
+	case FormatYAML:
+		return yamlRenderer{}, nil
+	case FormatCSV:
+		return delimitedRenderer{comma: ','}, nil
+	case FormatTSV:
+		return delimitedRenderer{comma: '\t'}, nil
+	case FormatMarkdown:
+		return markdownRenderer{}, nil
+	}
+	names := make([]string, len(Formats))
+	for i, known := range Formats {
+		names[i] = string(known)
+	}
+	return nil, fmt.Errorf("unknown output format %q (use %s, go-template=TEMPLATE or jsonpath=EXPR)", string(f), strings.Join(names, ", "))
+}
+
+type tableRenderer struct{}
+
+func (tableRenderer) Render(w io.Writer, out Output) error {
+	s := out.Text
+	if s == "" && out.Table != nil {
+		s = out.Table.Render()
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+type jsonRenderer struct{}
+
+func (jsonRenderer) Render(w io.Writer, out Output) error {
+	data, err := json.MarshalIndent(out.Data, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// ndjsonRenderer writes each element of a slice as one compact JSON line,
+// and anything else as a single line.
+type ndjsonRenderer struct{}
+
+func (ndjsonRenderer) Render(w io.Writer, out Output) error {
+	items := []interface{}{out.Data}
+	if v := reflect.ValueOf(out.Data); v.Kind() == reflect.Slice {
+		items = make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = v.Index(i).Interface()
+		}
+	}
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type yamlRenderer struct{}
+
+func (yamlRenderer) Render(w io.Writer, out Output) error {
+	data, err := json.Marshal(out.Data)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, jsonToYAML(data))
+	return err
+}
+
+// cells returns the table's header, unless hidden, and rows padded to the
+// header width. ANSI sequences are dropped along with the blank space a
+// Swatch leaves.
+func cells(t *Table) [][]string {
+	if t == nil {
+		return nil
+	}
+	var records [][]string
+	if !t.hideHeaders {
+		records = append(records, append([]string(nil), t.headers...))
+	}
+	for _, row := range t.rows {
+		rec := make([]string, len(t.headers))
+		for i := range rec {
+			if i < len(row) {
+				rec[i] = strings.TrimSpace(ansiEscape.ReplaceAllString(row[i], ""))
+			}
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+// delimitedRenderer writes CSV, quoting as needed. TSV has no quoting, so
+// tabs and line breaks inside a cell are replaced with spaces instead.
+type delimitedRenderer struct {
+	comma rune
+}
+
+var tsvCleaner = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+
+func (d delimitedRenderer) Render(w io.Writer, out Output) error {
+	records := cells(out.Table)
+	if d.comma == '\t' {
+		var buf bytes.Buffer
+		for _, rec := range records {
+			for i := range rec {
+				rec[i] = tsvCleaner.Replace(rec[i])
+			}
+			buf.WriteString(strings.Join(rec, "\t") + "\n")
+		}
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = d.comma
+	cw.WriteAll(records)
+	return cw.Error()
+}
+
+type markdownRenderer struct{}
+
+var markdownCleaner = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+
+func (markdownRenderer) Render(w io.Writer, out Output) error {
+	if out.Table == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	for i, rec := range cells(out.Table) {
+		for j := range rec {
+			rec[j] = markdownCleaner.Replace(rec[j])
+		}
+		buf.WriteString("| " + strings.Join(rec, " | ") + " |\n")
+		if i == 0 && !out.Table.hideHeaders {
+			sep := make([]string, len(rec))
+			for j := range sep {
+				sep[j] = "---"
+			}
+			buf.WriteString("| " + strings.Join(sep, " | ") + " |\n")
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
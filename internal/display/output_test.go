@@ -0,0 +1,227 @@
+package display
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+type outputItem struct {
+	Name  string  `json:"name"`
+	Room  string  `json:"room"`
+	Level float64 `json:"level"`
+}
+
+func sampleOutput() Output {
+	tbl := NewTable("Name", "Room")
+	tbl.AddRow("Desk Lamp", "Office")
+	tbl.AddRow("Porch, Front", Swatch(255, 0, 0)+" red")
+	tbl.AddRow("Tab\there", "a|b\nc")
+	tbl.AddRow("Short")
+	return Output{
+		Data:  []outputItem{{Name: "Desk Lamp", Room: "Office", Level: 40}, {Name: "Fan", Room: "Hall", Level: 0.5}},
+		Table: tbl,
+	}
+}
+
+func render(t *testing.T, f Format, out Output) string {
+	t.Helper()
+	var buf bytes.Buffer
+	r, err := NewRenderer(f)
+	if err != nil {
+		t.Fatalf("%s: unexpected error: %v", f, err)
+	}
+	if err := r.Render(&buf, out); err != nil {
+		t.Fatalf("%s: unexpected error: %v", f, err)
+	}
+	return buf.String()
+}
+
+func TestFormatSet(t *testing.T) {
+	var f Format
+	for _, name := range Formats {
+		if err := f.Set(string(name)); err != nil || f != name {
+			t.Errorf("Set(%q): got %q, %v", name, f, err)
+		}
+	}
+	if f.String() != "markdown" || f.Type() != "format" {
+		t.Errorf("unexpected String/Type: %q %q", f.String(), f.Type())
+	}
+	err := f.Set("xml")
+	if err == nil || err.Error() != `unknown output format "xml" (use table, json, ndjson, yaml, csv, tsv, markdown, go-template=TEMPLATE or jsonpath=EXPR)` {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if f != FormatMarkdown {
+		t.Errorf("a failed Set changed the format to %q", f)
+	}
+	if err := f.Set("jsonpath={.name}"); err != nil || f.Kind() != FormatJSONPath {
+		t.Errorf("Set(jsonpath): got %q, %v", f, err)
+	}
+}
+
+func TestRenderTable(t *testing.T) {
+	out := sampleOutput()
+	if got := render(t, FormatTable, out); got != out.Table.Render() {
+		t.Errorf("unexpected table output: %q", got)
+	}
+	out.Text = "success\n"
+	if got := render(t, FormatTable, out); got != "success\n" {
+		t.Errorf("expected Text to win, got %q", got)
+	}
+	if got := render(t, FormatTable, Output{}); got != "" {
+		t.Errorf("expected empty output, got %q", got)
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	got := render(t, FormatJSON, sampleOutput())
+	if !strings.HasPrefix(got, "[\n  {\n    \"name\": \"Desk Lamp\"") || !strings.HasSuffix(got, "]\n") {
+		t.Errorf("unexpected json output: %q", got)
+	}
+}
+
+func TestRenderNDJSON(t *testing.T) {
+	got := render(t, FormatNDJSON, sampleOutput())
+	want := `{"name":"Desk Lamp","room":"Office","level":40}` + "\n" + `{"name":"Fan","room":"Hall","level":0.5}` + "\n"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if got := render(t, FormatNDJSON, Output{Data: map[string]int{"rooms": 2}}); got != "{\"rooms\":2}\n" {
+		t.Errorf("unexpected single-value output: %q", got)
+	}
+}
+
+func TestRenderYAML(t *testing.T) {
+	data := map[string]interface{}{
+		"devices": []interface{}{
+			map[string]interface{}{"name": "Lamp", "on": true, "state": map[string]interface{}{}},
+			[]interface{}{1, "two"},
+		},
+		"empty":   []interface{}{},
+		"error":   nil,
+		"nested":  map[string]interface{}{"level": 1.5},
+		"strings": []string{"", "yes", "42", "- dash", "a: b", "trailing ", "line\nbreak", "plain text"},
+	}
+	want := `devices:
+  - name: Lamp
+    "on": true
+    state: {}
+  - - 1
+    - two
+empty: []
+error: null
+nested:
+  level: 1.5
+strings:
+  - ""
+  - "yes"
+  - "42"
+  - "- dash"
+  - "a: b"
+  - "trailing "
+  - "line\nbreak"
+  - plain text
+`
+	if got := render(t, FormatYAML, Output{Data: data}); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+	if got := render(t, FormatYAML, Output{Data: []int{}}); got != "[]\n" {
+		t.Errorf("unexpected empty list output: %q", got)
+	}
+}
+
+func TestRenderYAMLKeepsFieldOrder(t *testing.T) {
+	got := render(t, FormatYAML, sampleOutput())
+	want := "- name: Desk Lamp\n  room: Office\n  level: 40\n- name: Fan\n  room: Hall\n  level: 0.5\n"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestDecodeOrderedErrors(t *testing.T) {
+	for _, data := range []string{"", `{"a":`, `[1,`} {
+		dec := jsonDecoder(data)
+		if _, err := decodeOrdered(dec); err == nil {
+			t.Errorf("%q: expected error, got nil", data)
+		}
+	}
+}
+
+func TestRenderCSV(t *testing.T) {
+	got := render(t, FormatCSV, sampleOutput())
+	want := "Name,Room\nDesk Lamp,Office\n\"Porch, Front\",red\nTab\there,\"a|b\nc\"\nShort,\n"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if got := render(t, FormatCSV, Output{}); got != "" {
+		t.Errorf("expected no output without a table, got %q", got)
+	}
+}
+
+func TestRenderTSV(t *testing.T) {
+	got := render(t, FormatTSV, sampleOutput())
+	want := "Name\tRoom\nDesk Lamp\tOffice\nPorch, Front\tred\nTab here\ta|b c\nShort\t\n"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	out := sampleOutput()
+	got := render(t, FormatMarkdown, out)
+	want := "| Name | Room |\n| --- | --- |\n| Desk Lamp | Office |\n| Porch, Front | red |\n| Tab\there | a\\|b<br>c |\n| Short |  |\n"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if out.Table.Render() != sampleOutput().Table.Render() {
+		t.Error("rendering changed the table")
+	}
+	if got := render(t, FormatMarkdown, Output{}); got != "" {
+		t.Errorf("expected no output without a table, got %q", got)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	bad := Output{Data: []interface{}{make(chan int)}, Table: NewTable("A")}
+	for _, f := range []Format{FormatJSON, FormatNDJSON, FormatYAML, "jsonpath={.name}", "field=name"} {
+		r, _ := NewRenderer(f)
+		if err := r.Render(&bytes.Buffer{}, bad); err == nil {
+			t.Errorf("%s: expected marshal error, got nil", f)
+		}
+	}
+	for _, f := range append(Formats, "go-template={{.}}", "jsonpath={[0].name}", "field=name") {
+		r, _ := NewRenderer(f)
+		if err := r.Render(failWriter{}, sampleOutput()); err == nil {
+			t.Errorf("%s: expected write error, got nil", f)
+		}
+	}
+}
+
+func jsonDecoder(data string) *json.Decoder {
+	return json.NewDecoder(strings.NewReader(data))
+}
+
+func TestRenderHiddenHeaders(t *testing.T) {
+	tbl := NewTable("Name", "Room")
+	tbl.AddRow("Lamp", "Office")
+	tbl.HideHeaders()
+	out := Output{Table: tbl}
+
+	tests := map[Format]string{
+		FormatTable:    "Lamp | Office\n",
+		FormatCSV:      "Lamp,Office\n",
+		FormatTSV:      "Lamp\tOffice\n",
+		FormatMarkdown: "| Lamp | Office |\n",
+	}
+	for f, want := range tests {
+		if got := render(t, f, out); got != want {
+			t.Errorf("%s: expected %q, got %q", f, want, got)
+		}
+	}
+}
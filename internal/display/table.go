@@ -2,12 +2,15 @@ package display
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 )
 
 type Table struct {
-	headers []string
-	rows    [][]string
+	headers     []string
+	rows        [][]string
+	highlight   map[[2]int]bool
+	hideHeaders bool
 }
 
 func NewTable(headers ...string) *Table {
@@ -18,6 +21,22 @@ func (t *Table) AddRow(cols ...string) {
 	t.rows = append(t.rows, cols)
 }
 
+// Highlight marks a cell (zero-based data row and column) to be rendered in
+// reverse video when ColorEnabled. Padding is computed on the raw value so
+// columns stay aligned.
+func (t *Table) Highlight(row, col int) {
+	if t.highlight == nil {
+		t.highlight = map[[2]int]bool{}
+	}
+	t.highlight[[2]int{row, col}] = true
+}
+
+// HideHeaders leaves out the header row, and the separator under it, in
+// every format.
+func (t *Table) HideHeaders() {
+	t.hideHeaders = true
+}
+
 func (t *Table) Render() string {
 	if len(t.headers) == 0 {
 		return ""
@@ -25,48 +44,73 @@ func (t *Table) Render() string {
 
 	// Calculate column widths
 	widths := make([]int, len(t.headers))
-	for i, h := range t.headers {
-		widths[i] = len(h)
+	if !t.hideHeaders {
+		for i, h := range t.headers {
+			widths[i] = len(h)
+		}
 	}
 	for _, row := range t.rows {
 		for i, col := range row {
-			if i < len(widths) && len(col) > widths[i] {
-				widths[i] = len(col)
+			if i < len(widths) && visibleLen(col) > widths[i] {
+				widths[i] = visibleLen(col)
 			}
 		}
 	}
 
 	var sb strings.Builder
 
-	// Header row
-	sb.WriteString(renderRow(t.headers, widths))
-	sb.WriteByte('\n')
+	if !t.hideHeaders {
+		// Header row
+		sb.WriteString(renderRow(t.headers, widths, nil))
+		sb.WriteByte('\n')
 
-	// Separator
-	sb.WriteString(renderSeparator(widths))
-	sb.WriteByte('\n')
+		// Separator
+		sb.WriteString(renderSeparator(widths))
+		sb.WriteByte('\n')
+	}
 
 	// Data rows
-	for _, row := range t.rows {
-		sb.WriteString(renderRow(row, widths))
+	color := ColorEnabled()
+	for r, row := range t.rows {
+		sb.WriteString(renderRow(row, widths, func(c int) bool { return color && t.highlight[[2]int{r, c}] }))
 		sb.WriteByte('\n')
 	}
 
 	return sb.String()
 }
 
-func renderRow(cols []string, widths []int) string {
+func renderRow(cols []string, widths []int, highlighted func(int) bool) string {
 	parts := make([]string, len(widths))
 	for i := range widths {
 		val := ""
 		if i < len(cols) {
 			val = cols[i]
 		}
-		parts[i] = fmt.Sprintf("%-*s", widths[i], val)
+		parts[i] = val + strings.Repeat(" ", widths[i]-visibleLen(val))
+		if highlighted != nil && highlighted(i) {
+			parts[i] = "\033[7m" + parts[i] + "\033[0m"
+		}
 	}
 	return strings.Join(parts, " | ")
 }
 
+var ansiEscape = regexp.MustCompile("\033\\[[0-9;]*m")
+
+// visibleLen is the length of s without ANSI color sequences, so cells
+// containing a Swatch still line up.
+func visibleLen(s string) int {
+	return len(ansiEscape.ReplaceAllString(s, ""))
+}
+
+// Swatch renders a two-character block in the given 24-bit color, or
+// returns "" when color is disabled.
+func Swatch(r, g, b uint8) string {
+	if !ColorEnabled() {
+		return ""
+	}
+	return fmt.Sprintf("\033[48;2;%d;%d;%dm  \033[0m", r, g, b)
+}
+
 func renderSeparator(widths []int) string {
 	parts := make([]string, len(widths))
 	for i, w := range widths {
@@ -1,6 +1,7 @@
 package display
 
 import (
+	"os"
 	"strings"
 	"testing"
 )
@@ -65,3 +66,78 @@ func TestTableNoHeaders(t *testing.T) {
 		t.Error("expected empty string for no headers")
 	}
 }
+
+// fakeTerminal makes ColorEnabled true for the rest of the test.
+func fakeTerminal(t *testing.T) {
+	t.Helper()
+	t.Setenv("NO_COLOR", "")
+	isTerminal := IsTerminal
+	IsTerminal = func(*os.File) bool { return true }
+	t.Cleanup(func() { IsTerminal = isTerminal })
+}
+
+func TestTableHighlight(t *testing.T) {
+	fakeTerminal(t)
+	tbl := NewTable("Name", "Value")
+	tbl.AddRow("Lamp", "on")
+	tbl.AddRow("Fan", "off")
+	tbl.Highlight(1, 1)
+
+	out := tbl.Render()
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+
+	if strings.Contains(lines[2], "\033[7m") {
+		t.Errorf("row 0 should not be highlighted: %q", lines[2])
+	}
+	if !strings.Contains(lines[3], "\033[7moff  \033[0m") {
+		t.Errorf("expected padded highlighted cell, got %q", lines[3])
+	}
+}
+
+func TestTableRenderSwatchAlignment(t *testing.T) {
+	fakeTerminal(t)
+	tbl := NewTable("Property", "Value")
+	tbl.AddRow("color", Swatch(255, 102, 0)+" #FF6600")
+	tbl.AddRow("hue", "24")
+
+	out := tbl.Render()
+	if !strings.Contains(out, "\033[48;2;255;102;0m  \033[0m #FF6600") {
+		t.Errorf("expected swatch in output: %q", out)
+	}
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	// "   #FF6600" is 10 visible characters wide
+	if lines[1] != "---------|-----------" {
+		t.Errorf("separator should ignore escape sequences: %q", lines[1])
+	}
+	if lines[3] != "hue      | 24        " {
+		t.Errorf("unexpected padding: %q", lines[3])
+	}
+}
+
+func TestSwatchWithoutTerminal(t *testing.T) {
+	if s := Swatch(255, 102, 0); s != "" {
+		t.Errorf("expected no swatch outside a terminal, got %q", s)
+	}
+
+	fakeTerminal(t)
+	t.Setenv("NO_COLOR", "1")
+	if s := Swatch(255, 102, 0); s != "" {
+		t.Errorf("expected no swatch with NO_COLOR, got %q", s)
+	}
+}
+
+func TestTableHighlightWithoutTerminal(t *testing.T) {
+	tbl := NewTable("Name", "Value")
+	tbl.AddRow("Lamp", "on")
+	tbl.Highlight(0, 1)
+
+	if out := tbl.Render(); strings.Contains(out, "\033") {
+		t.Errorf("expected no escape sequences outside a terminal, got %q", out)
+	}
+
+	fakeTerminal(t)
+	t.Setenv("NO_COLOR", "1")
+	if out := tbl.Render(); strings.Contains(out, "\033") {
+		t.Errorf("expected no escape sequences with NO_COLOR, got %q", out)
+	}
+}
@@ -0,0 +1,28 @@
+package display
+
+import (
+	"fmt"
+	"io"
+	"text/template"
+)
+
+// templateRenderer executes a Go template against Data, so fields use their
+// Go names: {{range .}}{{.Name}}{{"\n"}}{{end}}.
+type templateRenderer struct {
+	tmpl *template.Template
+}
+
+func newTemplateRenderer(text string) (Renderer, error) {
+	if text == "" {
+		return nil, fmt.Errorf("go-template needs a template, e.g. go-template='{{.Name}}'")
+	}
+	tmpl, err := template.New("output").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid go-template: %w", err)
+	}
+	return templateRenderer{tmpl: tmpl}, nil
+}
+
+func (r templateRenderer) Render(w io.Writer, out Output) error {
+	return r.tmpl.Execute(w, out.Data)
+}
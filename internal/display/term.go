@@ -0,0 +1,17 @@
+package display
+
+import "os"
+
+// IsTerminal reports whether f is a terminal rather than a pipe or file.
+// It is a variable so tests can pretend to have one.
+var IsTerminal = func(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// ColorEnabled reports whether escape sequences for color and highlighting
+// should be written: stdout must be a terminal and NO_COLOR must be unset
+// or empty (https://no-color.org).
+func ColorEnabled() bool {
+	return os.Getenv("NO_COLOR") == "" && IsTerminal(os.Stdout)
+}
@@ -0,0 +1,33 @@
+package display
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsTerminal(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if IsTerminal(f) {
+		t.Error("a regular file is not a terminal")
+	}
+	f.Close()
+	if IsTerminal(f) {
+		t.Error("a closed file is not a terminal")
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	fakeTerminal(t)
+	if !ColorEnabled() {
+		t.Error("expected color on a terminal")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if ColorEnabled() {
+		t.Error("expected NO_COLOR to disable color")
+	}
+}
@@ -0,0 +1,152 @@
+package display
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// yamlField is one key of a JSON object, kept in document order so YAML
+// output lists fields the same way as the JSON output does.
+type yamlField struct {
+	key   string
+	value interface{}
+}
+
+type yamlMap []yamlField
+
+// jsonToYAML converts the output of json.Marshal to block-style YAML.
+func jsonToYAML(data []byte) string {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	// data comes from json.Marshal, so it always decodes
+	v, _ := decodeOrdered(dec)
+	return strings.Join(yamlLines(v), "\n") + "\n"
+}
+
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		m := yamlMap{}
+		for dec.More() {
+			key, _ := dec.Token()
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, yamlField{key: key.(string), value: value})
+		}
+		_, err = dec.Token()
+		return m, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for dec.More() {
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err = dec.Token()
+		return list, err
+	}
+	return tok, nil
+}
+
+// yamlLines renders v with nested values indented relative to column 0.
+func yamlLines(v interface{}) []string {
+	var lines []string
+	switch v := v.(type) {
+	case yamlMap:
+		if len(v) == 0 {
+			return []string{"{}"}
+		}
+		for _, f := range v {
+			key := yamlScalar(f.key)
+			if inline(f.value) {
+				lines = append(lines, key+": "+yamlLines(f.value)[0])
+				continue
+			}
+			lines = append(lines, key+":")
+			for _, l := range yamlLines(f.value) {
+				lines = append(lines, "  "+l)
+			}
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			return []string{"[]"}
+		}
+		for _, item := range v {
+			for i, l := range yamlLines(item) {
+				if i == 0 {
+					lines = append(lines, "- "+l)
+				} else {
+					lines = append(lines, "  "+l)
+				}
+			}
+		}
+	default:
+		lines = []string{yamlScalar(v)}
+	}
+	return lines
+}
+
+// inline reports whether v fits on the same line as its key.
+func inline(v interface{}) bool {
+	switch v := v.(type) {
+	case yamlMap:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	return true
+}
+
+func yamlScalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	}
+	s := v.(string)
+	if needsQuotes(s) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+var yamlReserved = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"y": true, "n": true, "null": true, "~": true,
+}
+
+// needsQuotes reports whether s would be read back as something other than
+// the same plain string.
+func needsQuotes(s string) bool {
+	if s == "" || yamlReserved[strings.ToLower(s)] {
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@` ") || strings.HasSuffix(s, " ") {
+		return true
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return true
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f || !strconv.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package fuzzy
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Distance returns the Levenshtein edit distance between a and b, ignoring
+// case.
+func Distance(a, b string) int {
+	ra := []rune(strings.ToLower(a))
+	rb := []rune(strings.ToLower(b))
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// Score rates how close candidate is to query, lower being closer. An exact
+// match ignoring case scores 0 and a case-insensitive substring either way
+// scores 1. Otherwise the score is 1 plus the edit distance, and ok is false
+// once that distance is too large for the candidate to be a plausible typo.
+func Score(query, candidate string) (score int, ok bool) {
+	q, c := strings.ToLower(query), strings.ToLower(candidate)
+	switch {
+	case q == c:
+		return 0, true
+	case q != "" && c != "" && (strings.Contains(c, q) || strings.Contains(q, c)):
+		return 1, true
+	}
+	n := utf8.RuneCountInString(q)
+	d := Distance(q, c)
+	if d >= n || d > max(2, n/2) {
+		return 0, false
+	}
+	return 1 + d, true
+}
+
+// Match is a candidate and its Score.
+type Match struct {
+	Value string
+	Score int
+}
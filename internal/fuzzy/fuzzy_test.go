@@ -0,0 +1,49 @@
+package fuzzy
+
+import "testing"
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"Lamp", "lamp", 0},
+		{"kitten", "sitting", 3},
+		{"Ofice", "Office", 1},
+		{"Lmap", "Lamp", 2},
+		{"Küche", "kuche", 1},
+	}
+	for _, tt := range tests {
+		if got := Distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("Distance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		query, candidate string
+		score            int
+		ok               bool
+	}{
+		{"office/lamp", "Office/Lamp", 0, true},
+		{"Lamp", "Office/Desk Lamp", 1, true},
+		{"Office/Desk Lamp", "Desk Lamp", 1, true},
+		{"Ofice/Lamp", "Office/Lamp", 2, true},
+		{"Lmap", "Lamp", 3, true},
+		{"Goodnite", "Goodnight", 4, true},
+		{"Porch", "Lamp", 0, false},
+		{"TV", "Fan", 0, false},
+		{"", "Lamp", 0, false},
+		{"Bedroom/Lamp", "Kitchen/Light", 0, false},
+	}
+	for _, tt := range tests {
+		score, ok := Score(tt.query, tt.candidate)
+		if score != tt.score || ok != tt.ok {
+			t.Errorf("Score(%q, %q) = %d, %v, want %d, %v", tt.query, tt.candidate, score, ok, tt.score, tt.ok)
+		}
+	}
+}